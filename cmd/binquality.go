@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/biogo/hts/bam"
+	"github.com/biogo/hts/sam"
+	"github.com/fredericlemoine/fastqutils/fastq"
+	"github.com/fredericlemoine/fastqutils/io"
+	"github.com/fredericlemoine/fastqutils/pipeline"
+	"github.com/fredericlemoine/fastqutils/stats"
+	"github.com/spf13/cobra"
+)
+
+var binScheme, binTable string
+
+// binQualityCmd represents the bin-quality command
+var binQualityCmd = &cobra.Command{
+	Use:   "bin-quality",
+	Short: "Bins base qualities to reduce file size",
+	Long: `Bins base qualities to reduce file size
+
+	Base qualities are replaced by the value of their bin, given by a built-in scheme
+	(--scheme):
+	- illumina8: Illumina 8-level binning: 2-9 -> 6, 10-19 -> 15, 20-24 -> 22, 25-29 -> 27,
+	  30-34 -> 33, 35-39 -> 37, 40 and more -> 40;
+	- novaseq4: NovaSeq 4-level binning: 0-2 -> 2, 3-14 -> 12, 15-30 -> 23, 31 and more -> 37;
+	or by a bin table (--bin-table): a tab separated file with three columns, the lowest
+	and highest Phred scores of the bin, and the score replacing them. Qualities that are
+	not in any bin are not modified.
+
+	The mean quality of bases before and after binning is reported at the end.
+
+	To bin qualities in fastq files:
+	fastqutils bin-quality --scheme illumina8 -1 <fastq1> -2 <fastq2> --output1 <outfastq1> --output2 <outfastq2>
+
+	To bin qualities in bam files:
+	fastqutils bin-quality --scheme novaseq4 -b -i <inbam> -o <outbam>
+	`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var err error
+		var binner *stats.QualityBinner
+
+		if binner, err = qualityBinner(); err != nil {
+			log.Fatal(err)
+		}
+		if bamformat {
+			err = binQualityBam(inbam, outbam, binner)
+		} else {
+			var comp int
+			if comp, err = outputCompression(); err != nil {
+				log.Fatal(err)
+			}
+			err = binQualityFastq(input1, input2, output1, output2, comp, binner)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		nbases, before, after := binner.MeanQualities()
+		if nbases == 0 {
+			log.Print("No base quality has been binned")
+			return
+		}
+		log.Printf("Binned %d bases, mean quality before binning: %.3f, after: %.3f (%+.3f)", nbases, before, after, after-before)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(binQualityCmd)
+	binQualityCmd.PersistentFlags().StringVar(&binScheme, "scheme", "illumina8", "Built-in binning scheme, possible values: illumina8, novaseq4")
+	binQualityCmd.PersistentFlags().StringVar(&binTable, "bin-table", "none", "Tab separated file of bins (min score, max score, value), replacing --scheme")
+	binQualityCmd.PersistentFlags().BoolVarP(&bamformat, "bam", "b", false, "Whether the input is bam or fastq format")
+	binQualityCmd.PersistentFlags().StringVarP(&inbam, "input-bam", "i", "stdin", "Input bam file")
+	binQualityCmd.PersistentFlags().StringVarP(&outbam, "out-bam", "o", "stdout", "Output bam file")
+	binQualityCmd.PersistentFlags().StringVarP(&input1, "input1", "1", "stdin", "First read fastq file")
+	binQualityCmd.PersistentFlags().StringVarP(&input2, "input2", "2", "none", "Second read fastq file")
+	binQualityCmd.PersistentFlags().StringVar(&output1, "output1", "stdout", "Output file 1")
+	binQualityCmd.PersistentFlags().StringVar(&output2, "output2", "none", "Output file 2 (if paired)")
+	addEncodingFlags(binQualityCmd)
+	addCompressFlags(binQualityCmd)
+}
+
+// qualityBinner returns the binner corresponding to the command line
+// options.
+func qualityBinner() (b *stats.QualityBinner, err error) {
+	var bins []stats.Bin
+
+	if binTable != "none" {
+		bins, err = stats.ReadBins(binTable)
+	} else {
+		bins, err = stats.PresetBins(binScheme)
+	}
+	if err != nil {
+		return
+	}
+	return stats.NewQualityBinner(bins)
+}
+
+func binQualityFastq(input1, input2, output1, output2 string, comp int, binner *stats.QualityBinner) (err error) {
+	var parser io.Reader
+	var writer *io.FastqWriter
+	var enc stats.Encoding
+	var nbrecords int64
+
+	if parser, err = openFastqParser(input1, input2); err != nil {
+		return
+	}
+	defer parser.Close()
+
+	if enc, parser, err = qualityEncoding(encoding, parser); err != nil {
+		return
+	}
+	if enc.LogOdds() {
+		return fmt.Errorf("%s qualities are log-odds scores, and not Phred scores: please convert them first with convert-quality", enc)
+	}
+	offset := enc.Offset()
+
+	if !pairedInput(input2) {
+		output2 = "none"
+	}
+	if writer, err = io.NewFastqWriter(output1, output2, comp); err != nil {
+		return
+	}
+
+	err = pipeline.Run(parser, threads, func(entry1, entry2 *fastq.FastqEntry) (bool, error) {
+		binner.Bin(entry1.Quality, offset)
+		if entry2 != nil {
+			binner.Bin(entry2.Quality, offset)
+		}
+		return true, nil
+	}, func(entry1, entry2 *fastq.FastqEntry) error {
+		nbrecords++
+		return writer.Write(entry1, entry2)
+	})
+	// The output is closed even after an error, so that compressed
+	// streams are terminated
+	if cerr := writer.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return
+	}
+	log.Printf("Wrote %d fastq records", nbrecords)
+	return
+}
+
+func binQualityBam(inbam, outbam string, binner *stats.QualityBinner) (err error) {
+	var bamwriter *bam.Writer
+	var bamreader *bam.Reader
+	var rec *sam.Record
+	var infile, outfile *os.File
+
+	// Opening new bam reader
+	if inbam == "stdin" || inbam == "-" {
+		infile = os.Stdin
+	} else {
+		if infile, err = os.Open(inbam); err != nil {
+			return
+		}
+		defer infile.Close()
+	}
+	if bamreader, err = bam.NewReader(infile, 1); err != nil {
+		return
+	}
+	defer bamreader.Close()
+
+	// Opening new bam writer
+	if outbam == "stdout" || outbam == "-" {
+		outfile = os.Stdout
+	} else {
+		if outfile, err = os.Create(outbam); err != nil {
+			return
+		}
+	}
+	if bamwriter, err = bam.NewWriter(outfile, bamreader.Header(), 1); err != nil {
+		return
+	}
+
+	// Reading bam file, record by record: qualities are raw Phred scores
+	for {
+		if rec, err = bamreader.Read(); err != nil {
+			if err.Error() != "EOF" {
+				return
+			}
+			err = nil
+			break
+		}
+		binner.Bin(rec.Qual, 0)
+		if err = bamwriter.Write(rec); err != nil {
+			return
+		}
+	}
+
+	if err = bamwriter.Close(); err != nil {
+		return
+	}
+	if outfile != os.Stdout {
+		err = outfile.Close()
+	}
+	return
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/fredericlemoine/fastqutils/fastq"
+	"github.com/fredericlemoine/fastqutils/io"
+	"github.com/fredericlemoine/fastqutils/pipeline"
+	"github.com/fredericlemoine/fastqutils/stats"
+	"github.com/spf13/cobra"
+)
+
+var convertFrom = stats.EncodingFlag{Auto: true, AllowAuto: true}
+var convertTo = stats.EncodingFlag{Encoding: stats.ILLUMINA_1_8}
+
+// convertQualityCmd represents the convert-quality command
+var convertQualityCmd = &cobra.Command{
+	Use:   "convert-quality",
+	Short: "Converts base qualities from an encoding to another",
+	Long: `Converts base qualities from an encoding to another
+
+	Quality strings are rewritten from the --from encoding to the --to encoding. With
+	--from auto, the encoding is detected from the first --encoding-reads reads, and the
+	conversion fails if it is ambiguous.
+
+	Solexa scores are log-odds, and not Phred scores: they are converted to and from
+	Phred scores with the exact formulas, and not by shifting the offset.
+
+	The conversion stops with an error if a quality is out of the range of the --from
+	encoding, or cannot be represented in the --to encoding.
+
+	Example, to convert archived Illumina 1.5 data:
+	fastqutils convert-quality --from illumina1.5 --to illumina1.8 -1 <fastq1> -2 <fastq2> --output1 <outfastq1> --output2 <outfastq2>
+	`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var err error
+		var comp int
+
+		if comp, err = outputCompression(); err != nil {
+			log.Fatal(err)
+		}
+		if err = convertQualityFastq(input1, input2, output1, output2, comp, convertFrom, convertTo); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(convertQualityCmd)
+	convertQualityCmd.PersistentFlags().StringVarP(&input1, "input1", "1", "stdin", "First read fastq file")
+	convertQualityCmd.PersistentFlags().StringVarP(&input2, "input2", "2", "none", "Second read fastq file")
+	convertQualityCmd.PersistentFlags().StringVar(&output1, "output1", "stdout", "Output file 1")
+	convertQualityCmd.PersistentFlags().StringVar(&output2, "output2", "none", "Output file 2 (if paired)")
+	convertQualityCmd.PersistentFlags().Var(&convertFrom, "from", "Input quality encoding, possible values: auto, "+strings.Join(stats.EncodingNames(), ", "))
+	convertQualityCmd.PersistentFlags().Var(&convertTo, "to", "Output quality encoding, possible values: "+strings.Join(stats.EncodingNames(), ", "))
+	convertQualityCmd.PersistentFlags().IntVar(&encodingReads, "encoding-reads", stats.DefaultEncodingReads, "Number of reads examined to detect the quality encoding, with --from auto")
+	addCompressFlags(convertQualityCmd)
+}
+
+func convertQualityFastq(input1, input2, output1, output2 string, comp int, from, to stats.EncodingFlag) (err error) {
+	var parser io.Reader
+	var writer *io.FastqWriter
+	var converter *stats.QualityConverter
+	var fromEnc stats.Encoding
+	var nbrecords int64
+
+	if parser, err = openFastqParser(input1, input2); err != nil {
+		return
+	}
+	defer parser.Close()
+
+	if fromEnc, parser, err = qualityEncoding(from, parser); err != nil {
+		return
+	}
+	if converter, err = stats.NewQualityConverter(fromEnc, to.Encoding); err != nil {
+		return
+	}
+
+	if !pairedInput(input2) {
+		output2 = "none"
+	}
+	if writer, err = io.NewFastqWriter(output1, output2, comp); err != nil {
+		return
+	}
+
+	err = pipeline.Run(parser, threads, func(entry1, entry2 *fastq.FastqEntry) (bool, error) {
+		if err := converter.Convert(entry1.Quality); err != nil {
+			return false, fmt.Errorf("read %s: %v", entry1.Name[1:], err)
+		}
+		if entry2 != nil {
+			if err := converter.Convert(entry2.Quality); err != nil {
+				return false, fmt.Errorf("read %s: %v", entry2.Name[1:], err)
+			}
+		}
+		return true, nil
+	}, func(entry1, entry2 *fastq.FastqEntry) error {
+		nbrecords++
+		return writer.Write(entry1, entry2)
+	})
+	// The output is closed even after an error, so that compressed
+	// streams are terminated
+	if cerr := writer.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return
+	}
+	log.Printf("Converted %d fastq records", nbrecords)
+	return
+}
@@ -21,15 +21,15 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>.
 package cmd
 
 import (
-	"bufio"
-	stdio "io"
 	"log"
 	"os"
+	"sync/atomic"
 
 	"github.com/biogo/hts/bam"
 	"github.com/biogo/hts/sam"
 	"github.com/fredericlemoine/fastqutils/fastq"
 	"github.com/fredericlemoine/fastqutils/io"
+	"github.com/fredericlemoine/fastqutils/pipeline"
 
 	"github.com/spf13/cobra"
 )
@@ -64,7 +64,11 @@ var filterLengthCmd = &cobra.Command{
 				log.Fatal(err)
 			}
 		} else {
-			if err = filterLengthFastq(input1, input2, output1, output2, gziped, dsrcOut, minLength, maxLength); err != nil {
+			var comp int
+			if comp, err = outputCompression(); err != nil {
+				log.Fatal(err)
+			}
+			if err = filterLengthFastq(input1, input2, output1, output2, comp, minLength, maxLength); err != nil {
 				log.Fatal(err)
 			}
 		}
@@ -81,76 +85,54 @@ func init() {
 	filterLengthCmd.PersistentFlags().BoolVarP(&bamformat, "bam", "b", false, "Whether the input is bam or fastq format")
 	filterLengthCmd.PersistentFlags().StringVar(&output1, "output1", "stdout", "Output file 1")
 	filterLengthCmd.PersistentFlags().StringVar(&output2, "output2", "none", "Output file 2 (if paired)")
-	filterLengthCmd.PersistentFlags().BoolVar(&gziped, "gz", false, "If true, will generate gziped file(s) : .gz extension is added automatically")
-	filterLengthCmd.PersistentFlags().BoolVar(&dsrcOut, "dsrc", false, "If true, will generate dsrc-compressed file(s) : .dsrc extension is added automatically (requires the 'dsrc' executable, see dsrc/README.md)")
+	addCompressFlags(filterLengthCmd)
 	filterLengthCmd.PersistentFlags().StringVarP(&input1, "input1", "1", "stdin", "First read fastq file")
 	filterLengthCmd.PersistentFlags().StringVarP(&input2, "input2", "2", "none", "Second read fastq file")
 }
 
-func filterLengthFastq(input1, input2, output1, output2 string, gziped, dsrced bool, minLength, maxLength int) (err error) {
-	var parser *io.FastQParser
-	var w1, w2 *bufio.Writer
-	var closer1, closer2 stdio.Closer
-	var remove1, remove2, toWrite bool
-	var entry1, entry2 *fastq.FastqEntry
+func filterLengthFastq(input1, input2, output1, output2 string, comp int, minLength, maxLength int) (err error) {
+	var parser io.Reader
+	var writer *io.FastqWriter
 
-	nbrecords := 0
-	discarded := 0
+	var nbrecords, discarded int64
 
 	if parser, err = openFastqParser(input1, input2); err != nil {
 		return
 	}
 	defer parser.Close()
 
-	if w1, closer1, err = io.GetWriter(output1, gziped, dsrced); err != nil {
+	if !pairedInput(input2) {
+		output2 = "none"
+	}
+	if writer, err = io.NewFastqWriter(output1, output2, comp); err != nil {
 		return
 	}
 
-	if input2 != "none" && output2 != "none" {
-		if w2, closer2, err = io.GetWriter(output2, gziped, dsrced); err != nil {
-			return
-		}
-	}
-
-	for {
-		entry1, entry2, err = parser.NextEntry()
-		if err != nil {
-			if err.Error() != "EOF" {
-				return
-			}
-			err = nil
-			break
-		}
-
-		remove1 = (minLength != -1 && (len(entry1.Sequence) < minLength)) || (maxLength != -1 && (len(entry1.Sequence) > maxLength))
-		remove2 = true
-		toWrite = true
+	err = pipeline.Run(parser, threads, func(entry1, entry2 *fastq.FastqEntry) (bool, error) {
+		remove1 := (minLength != -1 && (len(entry1.Sequence) < minLength)) || (maxLength != -1 && (len(entry1.Sequence) > maxLength))
+		remove2 := true
 
 		if entry2 != nil {
 			remove2 = (minLength != -1 && (len(entry2.Sequence) < minLength)) || (maxLength != -1 && (len(entry2.Sequence) > maxLength))
 		}
 
-		toWrite = (bothReads && !remove1 && !remove2) || (!bothReads && (!remove1 || !remove2))
+		toWrite := (bothReads && !remove1 && !remove2) || (!bothReads && (!remove1 || !remove2))
 
-		if toWrite {
-			io.WriteEntry(w1, entry1)
-			if w2 != nil {
-				io.WriteEntry(w2, entry2)
-			}
-			nbrecords++
-		} else {
-			discarded++
+		if !toWrite {
+			atomic.AddInt64(&discarded, 1)
 		}
+		return toWrite, nil
+	}, func(entry1, entry2 *fastq.FastqEntry) error {
+		nbrecords++
+		return writer.Write(entry1, entry2)
+	})
+	if err != nil {
+		return
 	}
 
-	if err = closer1.Close(); err != nil {
+	if err = writer.Close(); err != nil {
 		return
 	}
-	if input2 != "none" && output2 != "none" {
-		if err = closer2.Close(); err != nil {
-			return
-		}
-	}
 	log.Printf("Wrote %d fastq records", nbrecords)
 	log.Printf("Discarded %d fastq records", discarded)
 
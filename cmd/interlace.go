@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/fredericlemoine/fastqutils/fastq"
+	"github.com/fredericlemoine/fastqutils/io"
+	"github.com/spf13/cobra"
+)
+
+// interlaceCmd represents the interlace command
+var interlaceCmd = &cobra.Command{
+	Use:   "interlace",
+	Short: "Place the first and second reads of each pair consecutively in a single file",
+	Long: `Place the first and second reads of each pair consecutively in a single file.
+
+It is the inverse of deinterlace.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var err error
+		var parser io.Reader
+		var writer io.Writer
+
+		if input2 == "none" && !interleaved {
+			log.Fatal("interlace needs paired-end input: please give --input2")
+		}
+		if parser, err = openFastqParser(input1, input2); err != nil {
+			log.Fatal(err)
+		}
+		defer parser.Close()
+
+		if writer, err = openFastqWriter(output1, "none"); err != nil {
+			log.Fatal(err)
+		}
+
+		err = io.ForEach(parser, func(entry1, entry2 *fastq.FastqEntry) (err error) {
+			if err = writer.Write(entry1, nil); err != nil {
+				return
+			}
+			return writer.Write(entry2, nil)
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if err = writer.Close(); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(interlaceCmd)
+	interlaceCmd.PersistentFlags().StringVarP(&input1, "input1", "1", "stdin", "First read fastq file")
+	interlaceCmd.PersistentFlags().StringVarP(&input2, "input2", "2", "none", "Second read fastq file")
+	interlaceCmd.PersistentFlags().StringVarP(&output1, "output", "o", "stdout", "Interlaced output file")
+	addCompressFlags(interlaceCmd)
+}
@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"math"
+	"os"
+
+	"github.com/fredericlemoine/fastqutils/fastq"
+	"github.com/fredericlemoine/fastqutils/io"
+	"github.com/fredericlemoine/fastqutils/kmers"
+	"github.com/spf13/cobra"
+)
+
+var kmerSize int
+var kmerCanonical bool
+var kmerTop int
+var kmerMaxPositional int
+var kmerOutput string
+var kmerDump string
+var kmerDumpFormat string
+
+// kmersCmd represents the kmers command
+var kmersCmd = &cobra.Command{
+	Use:   "kmers",
+	Short: "Counts k-mers and reports the most over-represented ones",
+	Long: `Counts k-mers and reports the most over-represented ones
+
+	K-mers of every read (both reads of pairs) are counted, and the --top most
+	frequent k-mers are written as a tab separated table with columns:
+	- kmer: sequence of the k-mer;
+	- count: number of occurrences;
+	- percent: percentage of all counted k-mers;
+	- enrichment: maximum ratio, over read positions, of the observed number of
+	  occurrences of the k-mer to its expected number (if its occurrences were
+	  uniformly distributed along reads). A high enrichment at the beginning of
+	  reads is typical of primers, adapters or primer dimers;
+	- position: position of the maximum enrichment, starting at 1.
+
+	K-mers containing other characters than A, C, G and T are ignored. With
+	--canonical, a k-mer and its reverse complement are counted together.
+
+	Positions are counted only for the first --max-positional distinct k-mers, to
+	bound memory usage: the enrichment of other k-mers is NA.
+
+	The full count table can be saved with --dump, either as a tab separated file
+	(columns kmer and count, sorted by k-mer), or in a compact binary format
+	(see kmers.Counter.WriteBinary).
+
+	Examples:
+	fastqutils kmers -1 reads_1.fastq.gz -2 reads_2.fastq.gz -k 7 --top 20
+	fastqutils kmers -1 reads.fastq.gz -k 21 --canonical --dump counts.bin --dump-format binary
+	`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var err error
+		var counter *kmers.Counter
+		var dumpFormat int
+
+		if kmerTop < 0 {
+			log.Fatal("--top cannot be negative")
+		}
+		if dumpFormat, err = kmers.FormatFromString(kmerDumpFormat); err != nil {
+			log.Fatal(err)
+		}
+		if counter, err = countKmers(input1, input2); err != nil {
+			log.Fatal(err)
+		}
+		if err = writeTopKmers(kmerOutput, counter, kmerTop); err != nil {
+			log.Fatal(err)
+		}
+		if kmerDump != "none" {
+			if err = dumpKmers(kmerDump, counter, dumpFormat); err != nil {
+				log.Fatal(err)
+			}
+		}
+		log.Printf("Counted %d k-mers, %d distinct", counter.Total, counter.Len())
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(kmersCmd)
+	kmersCmd.PersistentFlags().StringVarP(&input1, "input1", "1", "stdin", "First read fastq file")
+	kmersCmd.PersistentFlags().StringVarP(&input2, "input2", "2", "none", "Second read fastq file")
+	kmersCmd.PersistentFlags().IntVarP(&kmerSize, "kmer-size", "k", 7, fmt.Sprintf("Length of k-mers, at most %d", kmers.MaxK))
+	kmersCmd.PersistentFlags().BoolVar(&kmerCanonical, "canonical", false, "Counts a k-mer and its reverse complement together")
+	kmersCmd.PersistentFlags().IntVarP(&kmerTop, "top", "n", 20, "Number of most frequent k-mers to report")
+	kmersCmd.PersistentFlags().IntVar(&kmerMaxPositional, "max-positional", kmers.DefaultMaxPositional, "Maximum number of distinct k-mers whose positions in reads are counted, which bounds memory usage")
+	kmersCmd.PersistentFlags().StringVarP(&kmerOutput, "output", "o", "stdout", "Output file of the most frequent k-mers")
+	kmersCmd.PersistentFlags().StringVar(&kmerDump, "dump", "none", "Output file of the full k-mer count table")
+	kmersCmd.PersistentFlags().StringVar(&kmerDumpFormat, "dump-format", "tsv", "Format of the k-mer count table, possible values: tsv, binary")
+}
+
+// countKmers counts the k-mers of every read of the given input file(s).
+func countKmers(input1, input2 string) (counter *kmers.Counter, err error) {
+	var parser io.Reader
+
+	if counter, err = kmers.NewCounter(kmerSize, kmerCanonical); err != nil {
+		return
+	}
+	counter.MaxPositional = kmerMaxPositional
+
+	if parser, err = openFastqParser(input1, input2); err != nil {
+		return
+	}
+	defer parser.Close()
+
+	// Records are not kept, their buffers can be reused
+	err = io.ForEach(io.ReuseEntries(parser), func(entry1, entry2 *fastq.FastqEntry) error {
+		counter.Add(entry1.Sequence)
+		if entry2 != nil {
+			counter.Add(entry2.Sequence)
+		}
+		return nil
+	})
+	return
+}
+
+// createOutput creates the given file, or returns os.Stdout for stdout
+// or -.
+func createOutput(file string) (*os.File, error) {
+	if file == "stdout" || file == "-" {
+		return os.Stdout, nil
+	}
+	return os.Create(file)
+}
+
+// closeOutput closes f, unless it is os.Stdout, and returns err, or
+// the closing error if err is nil.
+func closeOutput(f *os.File, err error) error {
+	if f == os.Stdout {
+		return err
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// writeTopKmers writes the n most frequent k-mers of counter in file.
+func writeTopKmers(file string, counter *kmers.Counter, n int) (err error) {
+	var f *os.File
+
+	if f, err = createOutput(file); err != nil {
+		return
+	}
+	w := bufio.NewWriter(f)
+	fmt.Fprintln(w, "kmer\tcount\tpercent\tenrichment\tposition")
+	for _, k := range counter.Top(n) {
+		if math.IsNaN(k.Enrichment) {
+			fmt.Fprintf(w, "%s\t%d\t%.4f\tNA\tNA\n", k.Sequence, k.Count, 100*k.Fraction)
+		} else {
+			fmt.Fprintf(w, "%s\t%d\t%.4f\t%.2f\t%d\n", k.Sequence, k.Count, 100*k.Fraction, k.Enrichment, k.Position+1)
+		}
+	}
+	return closeOutput(f, w.Flush())
+}
+
+// dumpKmers writes the full count table of counter in file.
+func dumpKmers(file string, counter *kmers.Counter, format int) (err error) {
+	var f *os.File
+
+	if f, err = createOutput(file); err != nil {
+		return
+	}
+	return closeOutput(f, counter.WriteTable(f, format))
+}
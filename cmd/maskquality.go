@@ -21,8 +21,6 @@ along with this program.  If not, see <http://www.gnu.org/licenses/>.
 package cmd
 
 import (
-	"bufio"
-	stdio "io"
 	"log"
 	"os"
 
@@ -30,6 +28,7 @@ import (
 	"github.com/biogo/hts/sam"
 	"github.com/fredericlemoine/fastqutils/fastq"
 	"github.com/fredericlemoine/fastqutils/io"
+	"github.com/fredericlemoine/fastqutils/pipeline"
 	"github.com/fredericlemoine/fastqutils/stats"
 
 	"github.com/spf13/cobra"
@@ -50,7 +49,11 @@ var qualityCmd = &cobra.Command{
 				log.Fatal(err)
 			}
 		} else {
-			if err := maskQualityFastq(input1, input2, encoding, output1, output2, gziped, dsrcOut, qual); err != nil {
+			comp, err := outputCompression()
+			if err != nil {
+				log.Fatal(err)
+			}
+			if err = maskQualityFastq(input1, input2, encoding, output1, output2, comp, qual); err != nil {
 				log.Fatal(err)
 			}
 		}
@@ -67,10 +70,9 @@ func init() {
 	qualityCmd.PersistentFlags().StringVar(&output2, "output2", "none", "Output file 2 (if paired)")
 	qualityCmd.PersistentFlags().StringVarP(&input1, "input1", "1", "stdin", "First read fastq file")
 	qualityCmd.PersistentFlags().StringVarP(&input2, "input2", "2", "none", "Second read fastq file")
-	qualityCmd.PersistentFlags().StringVar(&encoding, "encoding", "illumina1.8", "Base quality encoding, possible values: sanger, solexa, illumina1.3, illumina1.5, illumina1.8")
+	addEncodingFlags(qualityCmd)
 	qualityCmd.PersistentFlags().IntVarP(&qual, "quality", "q", 20, "Quality cutoff below which bases are masked")
-	qualityCmd.PersistentFlags().BoolVar(&gziped, "gz", false, "If true, will generate gziped file(s) : .gz extension is added automatically")
-	qualityCmd.PersistentFlags().BoolVar(&dsrcOut, "dsrc", false, "If true, will generate dsrc-compressed file(s) : .dsrc extension is added automatically (requires the 'dsrc' executable, see dsrc/README.md)")
+	addCompressFlags(qualityCmd)
 }
 
 func maskQUalityBam(inbam, outbam string, qual int) (err error) {
@@ -142,77 +144,49 @@ func maskQUalityBam(inbam, outbam string, qual int) (err error) {
 	return
 }
 
-func maskQualityFastq(input1, input2, encoding, output1, output2 string, gziped, dsrced bool, qual int) (err error) {
-	var w1, w2 *bufio.Writer
-	var closer1, closer2 stdio.Closer
-	var entry1, entry2 *fastq.FastqEntry
-	var parser *io.FastQParser
-	var enc int
-	var offset int
-
-	if enc, err = stats.EncodingFromString(encoding); err != nil {
-		return
-	}
-	if offset, err = stats.EncodingOffset(enc); err != nil {
-		return
-	}
+func maskQualityFastq(input1, input2 string, encoding stats.EncodingFlag, output1, output2 string, comp int, qual int) (err error) {
+	var writer *io.FastqWriter
+	var parser io.Reader
+	var enc stats.Encoding
 
 	if parser, err = openFastqParser(input1, input2); err != nil {
 		return
 	}
 	defer parser.Close()
 
-	if w1, closer1, err = io.GetWriter(output1, gziped, dsrced); err != nil {
+	if enc, parser, err = qualityEncoding(encoding, parser); err != nil {
 		return
 	}
-	if input2 != "none" && output2 != "none" {
-		if w2, closer2, err = io.GetWriter(output2, gziped, dsrced); err != nil {
-			return
-		}
-	}
+	offset := enc.Offset()
 
-	for {
-		entry1, entry2, err = parser.NextEntry()
-		if err != nil {
-			if err.Error() != "EOF" {
-				return
-			}
-			err = nil
-			break
-		}
-		for i, q := range entry1.Quality {
-			// If the base at the current index has bad quality, we replace it with a N
-			if int(q)-offset < qual {
-				entry1.Sequence[i] = 'N'
-			}
-		}
-		// NOTE: this used to call io.WriteEntryFasta here, which silently
-		// wrote 2-line FASTA records (name+sequence, no quality) even
-		// though this command reads and is meant to write FASTQ. Fixed to
-		// io.WriteEntry so quality-masked reads keep their quality scores
-		// and the output is valid FASTQ -- which --gz/--dsrc compression
-		// also depends on, since DSRC specifically expects 4-line FASTQ
-		// records.
-		io.WriteEntry(w1, entry1)
-		if w2 != nil {
-			for i, q := range entry2.Quality {
-				// If the base at the current index has bad quality, we replace it with a N
-				if int(q)-offset < qual {
-					entry2.Sequence[i] = 'N'
-				}
-			}
-			io.WriteEntry(w2, entry2)
-		}
+	if !pairedInput(input2) {
+		output2 = "none"
+	}
+	if writer, err = io.NewFastqWriter(output1, output2, comp); err != nil {
+		return
 	}
 
-	if err = closer1.Close(); err != nil {
+	err = pipeline.Run(parser, threads, func(entry1, entry2 *fastq.FastqEntry) (bool, error) {
+		maskQualityEntry(entry1, offset, qual)
+		if entry2 != nil {
+			maskQualityEntry(entry2, offset, qual)
+		}
+		return true, nil
+	}, writer.Write)
+	if err != nil {
 		return
 	}
 
-	if input2 != "none" && output2 != "none" {
-		if err = closer2.Close(); err != nil {
-			return
+	return writer.Close()
+}
+
+// maskQualityEntry replaces with N every base of entry whose quality
+// is below qual.
+func maskQualityEntry(entry *fastq.FastqEntry, offset, qual int) {
+	for i, q := range entry.Quality {
+		// If the base at the current index has bad quality, we replace it with a N
+		if int(q)-offset < qual {
+			entry.Sequence[i] = 'N'
 		}
 	}
-	return
 }
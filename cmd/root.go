@@ -2,11 +2,14 @@ package cmd
 
 import (
 	"fmt"
+	"log"
 	"math/rand"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/fredericlemoine/fastqutils/io"
+	"github.com/fredericlemoine/fastqutils/stats"
 	"github.com/spf13/cobra"
 )
 
@@ -16,6 +19,16 @@ var Version string = "Unknown"
 var input1 string
 var input2 string
 var seed int64
+var threads int
+var inputFormat string
+var strict bool
+var checkPairs string
+var interleaved bool
+var compress string
+var encoding = stats.EncodingFlag{Encoding: stats.ILLUMINA_1_8, AllowAuto: true}
+var encodingReads int
+var gziped bool  // deprecated, see --compress
+var dsrcOut bool // deprecated, see --compress
 
 // RootCmd represents the root Command
 var RootCmd = &cobra.Command{
@@ -31,6 +44,7 @@ Works for single and paired end files.
 `,
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
 		rand.Seed(seed)
+		io.SetThreads(threads)
 	},
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
@@ -45,20 +59,172 @@ func Execute() {
 	}
 }
 
-// openFastqParser opens a FastQParser for input1 (and input2, if paired).
+// openFastqParser opens a Reader for input1 (and input2, if paired), in
+// the format given by --input-format (fastq by default). If --interleaved
+// is given, input1 is read as an interleaved paired-end file. FASTQ records
+// are validated if --strict is given, and paired FASTQ files are checked
+// according to --check-pairs.
 // On success, callers are responsible for calling parser.Close() (e.g.
 // via defer) once they are done reading: for plain and .gz input this is
 // a no-op, but for .dsrc input it releases the backing decompression
 // subprocess.
-func openFastqParser(input1, input2 string) (fp *io.FastQParser, err error) {
-	if input2 != "none" {
-		fp, err = io.NewPairedEndParser(input1, input2)
+func openFastqParser(input1, input2 string) (fp io.Reader, err error) {
+	return openParser(input1, input2, interleaved)
+}
+
+// openParser is openFastqParser, input1 being read as an interleaved
+// paired-end file if interleavedInput is true, whatever --interleaved.
+func openParser(input1, input2 string, interleavedInput bool) (fp io.Reader, err error) {
+	var format int
+	if format, err = io.FormatFromString(inputFormat); err != nil {
+		return
+	}
+	if interleavedInput {
+		if input2 != "none" {
+			return nil, fmt.Errorf("--interleaved input is read from --input1 only, a second input file cannot be given")
+		}
+		fp, err = io.NewInterleavedReader(format, input1)
 	} else {
-		fp, err = io.NewSingleEndParser(input1)
+		fp, err = io.NewReader(format, input1, input2)
+	}
+	if err != nil {
+		return
+	}
+	if fqp, ok := fp.(*io.FastQParser); ok {
+		fqp.SetStrict(strict)
+		switch checkPairs {
+		case "none":
+		case "report":
+			fqp.SetPairCheck(io.PAIRS_REPORT)
+			fp = &pairReportingParser{fqp}
+		case "error":
+			fqp.SetPairCheck(io.PAIRS_STRICT)
+		default:
+			fp.Close()
+			return nil, fmt.Errorf("unknown --check-pairs value : %s, possible values are : none, report, error", checkPairs)
+		}
 	}
 	return
 }
 
+// pairReportingParser logs the pairing problems found by the parser
+// when it is closed.
+type pairReportingParser struct {
+	*io.FastQParser
+}
+
+func (p *pairReportingParser) Close() error {
+	report := p.PairReport()
+	if report.Mismatches > 0 {
+		log.Printf("Warning: %d pairs with different read names, first one: %v", report.Mismatches, report.FirstMismatch)
+	}
+	if report.Lengths != nil {
+		log.Printf("Warning: %v", report.Lengths)
+	}
+	return p.FastQParser.Close()
+}
+
+// pairedInput returns true if the input given by --input1/--input2
+// (and --interleaved) is paired-end.
+func pairedInput(input2 string) bool {
+	return input2 != "none" || interleaved
+}
+
+// addEncodingFlags adds the quality encoding flags to cmd: --encoding
+// and --encoding-reads (see qualityEncoding).
+func addEncodingFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().Var(&encoding, "encoding", "Base quality encoding, possible values: auto (detected from the first --encoding-reads reads), "+strings.Join(stats.EncodingNames(), ", ")+" (case insensitive, phred33 and phred64 are also accepted)")
+	cmd.PersistentFlags().IntVar(&encodingReads, "encoding-reads", stats.DefaultEncodingReads, "Number of reads examined to detect the quality encoding, with --encoding auto")
+}
+
+// qualityEncoding returns the quality encoding given by --encoding.
+// With auto, the encoding is detected from the first --encoding-reads
+// records of parser, and the returned Reader must be used instead of
+// parser: it returns every record, including the examined ones. The
+// detection fails, instead of guessing, if the encoding is ambiguous.
+func qualityEncoding(encoding stats.EncodingFlag, parser io.Reader) (enc stats.Encoding, r io.Reader, err error) {
+	if !encoding.Auto {
+		return encoding.Encoding, parser, nil
+	}
+	pr := io.NewPeekReader(parser, encodingReads)
+	detector := stats.NewEncodingDetector(0)
+	entries1, entries2 := pr.Peeked()
+	for i := range entries1 {
+		detector.Add(entries1[i])
+		if entries2[i] != nil {
+			detector.Add(entries2[i])
+		}
+	}
+	if enc, err = detector.Detect(); err != nil {
+		return enc, pr, fmt.Errorf("%v, please give it with --encoding", err)
+	}
+	log.Printf("Detected quality encoding: %s", enc)
+	return enc, pr, nil
+}
+
+// addCompressFlags adds the output compression flags to cmd:
+// --compress, and the deprecated --gz and --dsrc.
+func addCompressFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&compress, "compress", "none", "Output compression, possible values: none, gzip, bgzf (block gzip, with a .gzi index), bzip2, xz, zstd, dsrc (requires the 'dsrc' executable, see dsrc/README.md). The extension (.gz, .bz2, .xz, .zst, .dsrc) is added automatically")
+	cmd.PersistentFlags().BoolVar(&gziped, "gz", false, "If true, will generate gziped file(s) : .gz extension is added automatically")
+	cmd.PersistentFlags().BoolVar(&dsrcOut, "dsrc", false, "If true, will generate dsrc-compressed file(s) : .dsrc extension is added automatically")
+	cmd.PersistentFlags().MarkDeprecated("gz", "please use --compress gzip")
+	cmd.PersistentFlags().MarkDeprecated("dsrc", "please use --compress dsrc")
+}
+
+// addFastaCompressFlags adds the output compression flags of commands
+// writing fasta files to cmd: --compress, and the deprecated --gz.
+// DSRC only compresses fastq files: --compress dsrc is rejected before
+// the command runs.
+func addFastaCompressFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&compress, "compress", "none", "Output compression, possible values: none, gzip, bgzf (block gzip, with a .gzi index), bzip2, xz, zstd. The extension (.gz, .bz2, .xz, .zst) is added automatically")
+	cmd.PersistentFlags().BoolVar(&gziped, "gz", false, "If true, will generate gziped file(s) : .gz extension is added automatically")
+	cmd.PersistentFlags().MarkDeprecated("gz", "please use --compress gzip")
+	cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		if compress == "dsrc" {
+			return fmt.Errorf("dsrc compression only applies to fastq files, it cannot be used with %s", cmd.Name())
+		}
+		return nil
+	}
+}
+
+// outputCompression returns the output compression format given by
+// --compress, or by the deprecated --gz/--dsrc flags.
+func outputCompression() (comp int, err error) {
+	if gziped && dsrcOut {
+		return 0, fmt.Errorf("--gz and --dsrc are mutually exclusive: choose at most one output compression")
+	}
+	if (gziped || dsrcOut) && compress != "none" {
+		return 0, fmt.Errorf("--gz and --dsrc cannot be used with --compress")
+	}
+	if gziped {
+		return io.GZIP, nil
+	}
+	if dsrcOut {
+		return io.DSRC, nil
+	}
+	return io.CompressionFromString(compress)
+}
+
+// openFastqWriter opens a FASTQ Writer on output1 (and output2, unless
+// it is "none"), compressed according to --compress.
+func openFastqWriter(output1, output2 string) (w io.Writer, err error) {
+	var fw *io.FastqWriter
+	var comp int
+	if comp, err = outputCompression(); err != nil {
+		return
+	}
+	if fw, err = io.NewFastqWriter(output1, output2, comp); err != nil {
+		return
+	}
+	return fw, nil
+}
+
 func init() {
 	RootCmd.PersistentFlags().Int64VarP(&seed, "seed", "s", time.Now().UTC().UnixNano(), "Initial Random Seed")
+	RootCmd.PersistentFlags().IntVar(&threads, "threads", 1, "Number of threads used to process reads (mask quality, filter length, trim, tofasta, tobam...), to compress gzip/bgzf/bam output and to decompress gzip/bgzf input")
+	RootCmd.PersistentFlags().BoolVar(&strict, "strict", false, "Validate input fastq records, and stop at the first malformed record (see fastqutils validate)")
+	RootCmd.PersistentFlags().StringVar(&checkPairs, "check-pairs", "none", "Checks that paired fastq files are synchronized (same read names and same number of records), possible values: none, report (warns at the end), error (stops at the first problem)")
+	RootCmd.PersistentFlags().BoolVar(&interleaved, "interleaved", false, "Input fastq (or fasta) file given with --input1 is interleaved paired-end: consecutive records are first and second reads of a pair")
+	RootCmd.PersistentFlags().StringVar(&inputFormat, "input-format", "fastq", "Format of the input reads (for commands reading fastq files), possible values: fastq, fasta, bam")
 }
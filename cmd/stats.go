@@ -3,62 +3,207 @@ package cmd
 import (
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 
-	"github.com/fredericlemoine/fastqutils/fastq"
 	"github.com/fredericlemoine/fastqutils/io"
 	"github.com/fredericlemoine/fastqutils/stats"
 	"github.com/spf13/cobra"
 )
 
 var histos bool
+var adapterContent bool
+var perPosition bool
+var gcContent bool
+var duplication bool
+var maxTracked int
+var statsFormat string
+var sampleName string
+var sampleSheet string
 
 var statsCmd = &cobra.Command{
-	Use:   "stats",
+	Use:   "stats [fastq files...]",
 	Short: "Displays different statistics about fastq file(s)",
-	Long:  `Displays different statistics about fastq file(s)`,
+	Long: `Displays different statistics about fastq file(s)
+
+	Output formats (--format):
+	- text: human readable key/value lines, ASCII histograms and tables (default);
+	- json: a JSON object (schema documented with stats.WriteJSON);
+	- tsv: a long table with three columns: section, key and value (documented with stats.WriteTSV);
+	- multiqc: MultiQC custom content (General Statistics table), to save in a file
+	  whose name ends with _mqc.json. The sample name is given by --sample-name, or
+	  is the name of the first input file, without extensions.
+
+	Several samples may be given, either as arguments (single-end or interleaved files,
+	one sample per file), or with a sample sheet (--sample-sheet): a tab separated file
+	with columns sample, R1 and (optionally) R2. Statistics of samples are computed in
+	parallel (see --threads), and a summary table is written, with one row per sample
+	and a last row "all" aggregating all samples (see stats.WriteSummary for the json
+	format).
+
+	For paired-end input, statistics of first reads and of second reads are given
+	too: in text format, next to combined statistics (columns All, R1 and R2), and
+	in json and tsv formats, under read1 and read2 keys and sections.
+	`,
 	Run: func(cmd *cobra.Command, args []string) {
-		var parser *io.FastQParser
 		var err error
-		var nt byte
 		var stat stats.Stats
-		var strenc string
+		var format int
+		var samples []statsSample
 
-		if parser, err = openFastqParser(input1, input2); err != nil {
-			return
+		if format, err = stats.ReportFormatFromString(statsFormat); err != nil {
+			log.Fatal(err)
 		}
-		defer parser.Close()
 
-		if stat, err = stats.ComputeStats(parser, histos); err != nil {
-			log.Fatal(err)
+		if sampleSheet != "none" {
+			if samples, err = readSampleSheet(sampleSheet); err != nil {
+				log.Fatal(err)
+			}
+		} else if len(args) > 0 {
+			for _, f := range args {
+				samples = append(samples, statsSample{fileSampleName(f), f, "none"})
+			}
 		}
-		fmt.Print("NSeq\t")
-		fmt.Println(stat.NSeq)
-		fmt.Print("Paired\t")
-		fmt.Println(stat.Paired)
-		for i, v := range stat.TotalNt {
-			nt, _ = fastq.Nt(i)
-			fmt.Printf("%c", nt)
-			fmt.Print("\t")
-			fmt.Printf("%.2f\n", v)
+
+		if samples == nil {
+			if stat, err = computeStats(input1, input2); err != nil {
+				log.Fatal(err)
+			}
+			name := sampleName
+			if name == "" {
+				name = fileSampleName(input1)
+			}
+			if err = stats.WriteReport(os.Stdout, stat, format, name); err != nil {
+				log.Fatal(err)
+			}
+			return
 		}
-		if strenc, err = stats.EncodingToString(stat.Encoding); err != nil {
+
+		results, aggregate, err := computeSamplesStats(samples)
+		if err != nil {
 			log.Fatal(err)
 		}
-		fmt.Printf("Encoding\t%s\n", strenc)
-		fmt.Printf("AvgQual\t%.3f\n", stat.MeanQual)
-		fmt.Printf("MinQual\t%d\n", stat.MinQual)
-		fmt.Printf("MaxQual\t%d\n", stat.MaxQual)
-		if histos {
-			fmt.Printf("Quality Histogram\n%s\n", stat.QualHistogram.Draw(100))
-			fmt.Printf("Length Histogram\n%s\n", stat.LenHistogram.Draw(100))
+		if err = stats.WriteSummary(os.Stdout, results, aggregate, format); err != nil {
+			log.Fatal(err)
 		}
 	},
 }
 
+// statsSample is a sample given on the command line or in a sample sheet.
+type statsSample struct {
+	name           string
+	input1, input2 string // input2 is "none" for single-end samples
+}
+
 func init() {
 	RootCmd.AddCommand(statsCmd)
 	statsCmd.PersistentFlags().StringVarP(&input1, "input1", "1", "stdin", "First read fastq file")
 	statsCmd.PersistentFlags().StringVarP(&input2, "input2", "2", "none", "Second read fastq file")
 	statsCmd.PersistentFlags().BoolVar(&histos, "histograms", false, "Display length and quality histograms")
+	statsCmd.PersistentFlags().BoolVar(&perPosition, "per-position", false, "Display quality (mean, quartiles) and base composition by read position, for first and second reads separately")
+	statsCmd.PersistentFlags().BoolVar(&adapterContent, "adapters", false, "Display the content of known adapters by read position, and infer the adapter from over-represented 3' k-mers if no known adapter is found")
+	statsCmd.PersistentFlags().BoolVar(&gcContent, "gc", false, "Display the distribution of the GC content of reads, compared to a fitted normal distribution, and flag it as suspicious if it has several peaks or deviates from the normal distribution (possible contamination)")
+	statsCmd.PersistentFlags().BoolVar(&duplication, "duplication", false, "Display the estimated duplication levels of reads and of pairs, and the most over-represented sequences")
+	statsCmd.PersistentFlags().IntVar(&maxTracked, "max-tracked", stats.DefaultMaxTracked, "Maximum number of distinct sequences (and of distinct pairs) tracked to estimate duplication levels, which bounds memory usage")
+	statsCmd.PersistentFlags().StringVar(&statsFormat, "format", "text", "Output format, possible values: text, json, tsv, multiqc")
+	statsCmd.PersistentFlags().StringVar(&sampleName, "sample-name", "", "Sample name, for multiqc output (default: name of the first input file, without extensions)")
+	statsCmd.PersistentFlags().StringVar(&sampleSheet, "sample-sheet", "none", "Tab separated file of samples, with columns sample, R1 and R2 (optional)")
+}
+
+// computeStats computes the statistics of the given input file(s).
+func computeStats(input1, input2 string) (stat stats.Stats, err error) {
+	var parser io.Reader
+	var modules []stats.Module
+
+	if parser, err = openFastqParser(input1, input2); err != nil {
+		return
+	}
+	defer parser.Close()
+
+	if adapterContent {
+		modules = append(modules, stats.NewAdapterContent())
+	}
+	if gcContent {
+		modules = append(modules, stats.NewGCContent())
+	}
+	if duplication {
+		modules = append(modules, stats.NewDuplication(maxTracked))
+	}
+	return stats.ComputeStats(parser, histos, perPosition, modules...)
+}
+
+// computeSamplesStats computes the statistics of every sample, using
+// --threads goroutines, and their aggregate.
+func computeSamplesStats(samples []statsSample) (results []stats.SampleStats, aggregate stats.Stats, err error) {
+	var wg sync.WaitGroup
+	errs := make([]error, len(samples))
+	results = make([]stats.SampleStats, len(samples))
+	sem := make(chan struct{}, max(threads, 1))
+
+	for i, sample := range samples {
+		wg.Add(1)
+		go func(i int, sample statsSample) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i].Sample = sample.name
+			if results[i].Stats, errs[i] = computeStats(sample.input1, sample.input2); errs[i] != nil {
+				errs[i] = fmt.Errorf("sample %s: %w", sample.name, errs[i])
+			}
+		}(i, sample)
+	}
+	wg.Wait()
+
+	for i, r := range results {
+		if errs[i] != nil {
+			return nil, aggregate, errs[i]
+		}
+		if i == 0 {
+			aggregate = r.Stats
+		} else if aggregate, err = stats.Merge(aggregate, r.Stats); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// readSampleSheet reads a tab separated file with columns sample, R1
+// and (optionally) R2. Empty lines, lines starting with '#' and a
+// header line starting with "sample" are ignored.
+func readSampleSheet(file string) (samples []statsSample, err error) {
+	var b []byte
+	if b, err = os.ReadFile(file); err != nil {
+		return
+	}
+	for i, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" || strings.HasPrefix(line, "#") || (i == 0 && strings.HasPrefix(line, "sample")) {
+			continue
+		}
+		cols := strings.Split(line, "\t")
+		switch len(cols) {
+		case 2:
+			samples = append(samples, statsSample{cols[0], cols[1], "none"})
+		case 3:
+			samples = append(samples, statsSample{cols[0], cols[1], cols[2]})
+		default:
+			return nil, fmt.Errorf("%s, line %d: expected 2 or 3 tab separated columns (sample, R1, R2), found %d", file, i+1, len(cols))
+		}
+	}
+	if len(samples) == 0 {
+		err = fmt.Errorf("%s: no sample found", file)
+	}
+	return
+}
 
+// fileSampleName returns the name of the given file without directory
+// and extensions.
+func fileSampleName(file string) string {
+	name := filepath.Base(file)
+	if i := strings.Index(name, "."); i > 0 {
+		name = name[:i]
+	}
+	return name
 }
@@ -1,13 +1,13 @@
 package cmd
 
 import (
-	"bufio"
-	stdio "io"
 	"log"
 
 	"github.com/spf13/cobra"
 
+	"github.com/fredericlemoine/fastqutils/fastq"
 	"github.com/fredericlemoine/fastqutils/io"
+	"github.com/fredericlemoine/fastqutils/pipeline"
 )
 
 // tofastaCmd represents the tofasta command
@@ -16,48 +16,40 @@ var tofastaCmd = &cobra.Command{
 	Short: "Converts input fastq file into fasta",
 	Long:  `Converts input fastq file into fasta.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		var w1, w2 *bufio.Writer
-		var closer1, closer2 stdio.Closer
-		var parser *io.FastQParser
+		var writer io.Writer
+		var parser io.Reader
+		var comp int
 		var err error
 
 		if parser, err = openFastqParser(input1, input2); err != nil {
-			return
+			log.Fatal(err)
 		}
 		defer parser.Close()
 
-		// DSRC compresses raw FASTQ streams; it does not apply to FASTA
-		// output, so this command only offers --gz (no --dsrc flag).
-		if w1, closer1, err = io.GetWriter(output1, gziped, false); err != nil {
+		if comp, err = outputCompression(); err != nil {
 			log.Fatal(err)
 		}
-		if input2 != "none" && output2 != "none" {
-			if w2, closer2, err = io.GetWriter(output2, gziped, false); err != nil {
-				log.Fatal(err)
-			}
+		if !pairedInput(input2) {
+			output2 = "none"
 		}
-
-		for {
-			entry1, entry2, err := parser.NextEntry()
-			if err != nil {
-				if err.Error() != "EOF" {
-					log.Fatal(err)
-				}
-				break
-			}
-			io.WriteEntryFasta(w1, entry1)
-			if w2 != nil {
-				io.WriteEntryFasta(w2, entry2)
-			}
+		if writer, err = io.NewFastaWriter(output1, output2, comp); err != nil {
+			log.Fatal(err)
 		}
 
-		if err = closer1.Close(); err != nil {
+		// Records are only kept in batches by several workers: otherwise
+		// their buffers can be reused
+		reader := parser
+		if threads < 2 {
+			reader = io.ReuseEntries(parser)
+		}
+		err = pipeline.Run(reader, threads, func(entry1, entry2 *fastq.FastqEntry) (bool, error) {
+			return true, nil
+		}, writer.Write)
+		if err != nil {
 			log.Fatal(err)
 		}
-		if input2 != "none" && output2 != "none" {
-			if err = closer2.Close(); err != nil {
-				log.Fatal(err)
-			}
+		if err = writer.Close(); err != nil {
+			log.Fatal(err)
 		}
 	},
 }
@@ -65,7 +57,7 @@ var tofastaCmd = &cobra.Command{
 func init() {
 	RootCmd.AddCommand(tofastaCmd)
 
-	tofastaCmd.PersistentFlags().BoolVar(&gziped, "gz", false, "If true, will generate gziped file(s) : .gz extension is added automatically")
+	addFastaCompressFlags(tofastaCmd)
 	tofastaCmd.PersistentFlags().StringVarP(&input1, "input1", "1", "stdin", "First read fastq file")
 	tofastaCmd.PersistentFlags().StringVarP(&input2, "input2", "2", "none", "Second read fastq file")
 	tofastaCmd.PersistentFlags().StringVar(&output1, "output1", "stdout", "Output file 1")
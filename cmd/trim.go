@@ -0,0 +1,16 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// trimCmd represents the trim command
+var trimCmd = &cobra.Command{
+	Use:   "trim",
+	Short: "Commands to trim reads",
+	Long:  `Commands to trim reads.`,
+}
+
+func init() {
+	RootCmd.AddCommand(trimCmd)
+}
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"log"
+	"sync/atomic"
+
+	"github.com/spf13/cobra"
+
+	"github.com/fredericlemoine/fastqutils/fastq"
+	"github.com/fredericlemoine/fastqutils/io"
+	"github.com/fredericlemoine/fastqutils/pipeline"
+	"github.com/fredericlemoine/fastqutils/trim"
+)
+
+var adapterPreset, adapterFile string
+var adapterMismatchRate float64
+var adapterMinOverlap, adapterMinInsert int
+var noInsertOverlap bool
+
+// trimAdaptersCmd represents the trim adapters command
+var trimAdaptersCmd = &cobra.Command{
+	Use:   "adapters",
+	Short: "Remove adapter sequences from read 3' ends",
+	Long: `Remove adapter sequences from read 3' ends.
+
+	Adapters are given by a preset (--preset truseq, nextera or none) and/or
+	a fasta file (--adapters, searched in both reads). An adapter is found if the
+	read matches it with at most --max-mismatch-rate mismatches per base; at the end
+	of the read, partial adapters of at least --min-overlap bases are found.
+	The read is cut at the leftmost adapter found.
+
+	For paired-end input, adapters are also detected from the overlap of the two reads:
+	if the insert is shorter than the reads (at least --min-insert bases), the two reads
+	are cut at the end of the insert. Use --no-insert-overlap to disable it.
+
+	Reads shorter than --min-length after trimming are discarded, as in trim quality.
+
+	fastqutils trim adapters --preset truseq -1 <fastq1> -2 <fastq2> --output1 <outfastq1> --output2 <outfastq2>
+	`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var err error
+		var comp int
+		var t *trim.AdapterTrimmer
+
+		if comp, err = outputCompression(); err != nil {
+			log.Fatal(err)
+		}
+		if t, err = adapterTrimmer(); err != nil {
+			log.Fatal(err)
+		}
+		if err = trimAdaptersFastq(input1, input2, output1, output2, comp, t, trimMinLength); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	trimCmd.AddCommand(trimAdaptersCmd)
+	trimAdaptersCmd.PersistentFlags().StringVarP(&input1, "input1", "1", "stdin", "First read fastq file")
+	trimAdaptersCmd.PersistentFlags().StringVarP(&input2, "input2", "2", "none", "Second read fastq file")
+	trimAdaptersCmd.PersistentFlags().StringVar(&output1, "output1", "stdout", "Output file 1")
+	trimAdaptersCmd.PersistentFlags().StringVar(&output2, "output2", "none", "Output file 2 (if paired)")
+	trimAdaptersCmd.PersistentFlags().StringVar(&adapterPreset, "preset", "truseq", "Built-in adapters, possible values: truseq, nextera, none")
+	trimAdaptersCmd.PersistentFlags().StringVarP(&adapterFile, "adapters", "a", "none", "Fasta file of additional adapters")
+	trimAdaptersCmd.PersistentFlags().Float64Var(&adapterMismatchRate, "max-mismatch-rate", 0.1, "Maximum number of mismatches per base between the read and an adapter")
+	trimAdaptersCmd.PersistentFlags().IntVar(&adapterMinOverlap, "min-overlap", 3, "Minimum length of a partial adapter at the end of a read")
+	trimAdaptersCmd.PersistentFlags().IntVar(&adapterMinInsert, "min-insert", 20, "Minimum length of the insert, for adapter detection from paired-end overlap")
+	trimAdaptersCmd.PersistentFlags().BoolVar(&noInsertOverlap, "no-insert-overlap", false, "Do not detect adapters from paired-end overlap")
+	trimAdaptersCmd.PersistentFlags().IntVarP(&trimMinLength, "min-length", "l", 1, "Minimum length of trimmed reads")
+	trimAdaptersCmd.PersistentFlags().BoolVarP(&bothReads, "paired-both", "p", false, "Discards a pair (if paired-end) only if the two reads are shorter than --min-length. Otherwise, discards the pair if at least one read is too short.")
+	addCompressFlags(trimAdaptersCmd)
+}
+
+// adapterTrimmer returns the trimmer corresponding to the command
+// line options.
+func adapterTrimmer() (t *trim.AdapterTrimmer, err error) {
+	var adapters1, adapters2, user []trim.Adapter
+
+	if adapters1, adapters2, err = trim.PresetAdapters(adapterPreset); err != nil {
+		return
+	}
+	if adapterFile != "none" {
+		if user, err = trim.ReadAdapters(adapterFile); err != nil {
+			return
+		}
+		adapters1 = append(adapters1, user...)
+		adapters2 = append(adapters2, user...)
+	}
+	t = trim.NewAdapterTrimmer(adapters1, adapters2)
+	t.MaxMismatchRate = adapterMismatchRate
+	t.MinOverlap = adapterMinOverlap
+	t.MinInsert = adapterMinInsert
+	t.InsertOverlap = !noInsertOverlap
+	return
+}
+
+func trimAdaptersFastq(input1, input2, output1, output2 string, comp int, t *trim.AdapterTrimmer, minLength int) (err error) {
+	var parser io.Reader
+	var writer *io.FastqWriter
+	var nbrecords, discarded int64
+
+	if parser, err = openFastqParser(input1, input2); err != nil {
+		return
+	}
+	defer parser.Close()
+
+	if !pairedInput(input2) {
+		output2 = "none"
+	}
+	if writer, err = io.NewFastqWriter(output1, output2, comp); err != nil {
+		return
+	}
+
+	err = pipeline.Run(parser, threads, func(entry1, entry2 *fastq.FastqEntry) (bool, error) {
+		t.Trim(entry1, entry2)
+		if !keepTrimmed(entry1, entry2, minLength) {
+			atomic.AddInt64(&discarded, 1)
+			return false, nil
+		}
+		return true, nil
+	}, func(entry1, entry2 *fastq.FastqEntry) error {
+		nbrecords++
+		return writer.Write(entry1, entry2)
+	})
+	if err != nil {
+		return
+	}
+
+	if err = writer.Close(); err != nil {
+		return
+	}
+	hits1, hits2, overlap := t.Hits()
+	for i, a := range t.Adapters1 {
+		log.Printf("Adapter %s: %d first reads trimmed", a.Name, hits1[i])
+	}
+	if pairedInput(input2) {
+		for i, a := range t.Adapters2 {
+			log.Printf("Adapter %s: %d second reads trimmed", a.Name, hits2[i])
+		}
+		if t.InsertOverlap {
+			log.Printf("Insert overlap: %d pairs trimmed", overlap)
+		}
+	}
+	log.Printf("Wrote %d fastq records", nbrecords)
+	log.Printf("Discarded %d fastq records", discarded)
+	return
+}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"log"
+	"sync/atomic"
+
+	"github.com/spf13/cobra"
+
+	"github.com/fredericlemoine/fastqutils/fastq"
+	"github.com/fredericlemoine/fastqutils/io"
+	"github.com/fredericlemoine/fastqutils/pipeline"
+	"github.com/fredericlemoine/fastqutils/stats"
+	"github.com/fredericlemoine/fastqutils/trim"
+)
+
+var trimMethod, trimEnds string
+var trimWindow, trimMinLength int
+
+// trimQualityCmd represents the trim quality command
+var trimQualityCmd = &cobra.Command{
+	Use:   "quality",
+	Short: "Trim low quality bases from read ends",
+	Long: `Trim low quality bases from read ends.
+
+	Two methods are available (--method):
+	- sliding: a window of --window bases is moved from the end of the read towards its beginning,
+	  and its bases are removed as long as its mean quality is below --quality (as fastp --cut_tail);
+	- bwa: the read is cut where the sum of (--quality - base quality), computed from the end
+	  of the read, is maximal (as BWA -q and cutadapt -q).
+
+	The 5' end (--ends 5 or both) is trimmed the same way, from the beginning of the read.
+
+	Reads shorter than --min-length after trimming are discarded. For paired-end input,
+	the pair is discarded if at least one read is too short, or if the two reads are too
+	short with --paired-both.
+
+	fastqutils trim quality -q 20 -1 <fastq1> -2 <fastq2> --output1 <outfastq1> --output2 <outfastq2>
+	`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var err error
+		var comp int
+		var t *trim.QualityTrimmer
+
+		if inputFormat == "fasta" {
+			log.Fatal("fasta input has no qualities, reads cannot be trimmed on their quality")
+		}
+		if comp, err = outputCompression(); err != nil {
+			log.Fatal(err)
+		}
+		if t, err = qualityTrimmer(); err != nil {
+			log.Fatal(err)
+		}
+		if err = trimQualityFastq(input1, input2, output1, output2, comp, t, trimMinLength); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	trimCmd.AddCommand(trimQualityCmd)
+	trimQualityCmd.PersistentFlags().StringVarP(&input1, "input1", "1", "stdin", "First read fastq file")
+	trimQualityCmd.PersistentFlags().StringVarP(&input2, "input2", "2", "none", "Second read fastq file")
+	trimQualityCmd.PersistentFlags().StringVar(&output1, "output1", "stdout", "Output file 1")
+	trimQualityCmd.PersistentFlags().StringVar(&output2, "output2", "none", "Output file 2 (if paired)")
+	addEncodingFlags(trimQualityCmd)
+	trimQualityCmd.PersistentFlags().IntVarP(&qual, "quality", "q", 20, "Quality cutoff")
+	trimQualityCmd.PersistentFlags().StringVar(&trimMethod, "method", "sliding", "Trimming method, possible values: sliding, bwa")
+	trimQualityCmd.PersistentFlags().IntVarP(&trimWindow, "window", "w", 4, "Window size (sliding method only)")
+	trimQualityCmd.PersistentFlags().StringVar(&trimEnds, "ends", "both", "Read ends to trim, possible values: 3, 5, both")
+	trimQualityCmd.PersistentFlags().IntVarP(&trimMinLength, "min-length", "l", 1, "Minimum length of trimmed reads")
+	trimQualityCmd.PersistentFlags().BoolVarP(&bothReads, "paired-both", "p", false, "Discards a pair (if paired-end) only if the two reads are shorter than --min-length. Otherwise, discards the pair if at least one read is too short.")
+	addCompressFlags(trimQualityCmd)
+}
+
+// qualityTrimmer returns the trimmer corresponding to the command
+// line options. Its Offset is set once the quality encoding is known
+// (see trimQualityFastq).
+func qualityTrimmer() (t *trim.QualityTrimmer, err error) {
+	t = &trim.QualityTrimmer{Cutoff: qual, Window: trimWindow}
+	if t.Method, err = trim.MethodFromString(trimMethod); err != nil {
+		return
+	}
+	t.Ends, err = trim.EndsFromString(trimEnds)
+	return
+}
+
+// keepTrimmed tells whether trimmed reads must be written, given
+// their lengths and the --paired-both option. entry2 is nil for
+// single-end input.
+func keepTrimmed(entry1, entry2 *fastq.FastqEntry, minLength int) bool {
+	short1 := len(entry1.Sequence) < minLength
+	if entry2 == nil {
+		return !short1
+	}
+	short2 := len(entry2.Sequence) < minLength
+	if bothReads {
+		return !short1 || !short2
+	}
+	return !short1 && !short2
+}
+
+func trimQualityFastq(input1, input2, output1, output2 string, comp int, t *trim.QualityTrimmer, minLength int) (err error) {
+	var parser io.Reader
+	var writer *io.FastqWriter
+	var nbrecords, discarded int64
+	var enc stats.Encoding
+
+	if parser, err = openFastqParser(input1, input2); err != nil {
+		return
+	}
+	defer parser.Close()
+
+	if enc, parser, err = qualityEncoding(encoding, parser); err != nil {
+		return
+	}
+	t.Offset = enc.Offset()
+
+	if !pairedInput(input2) {
+		output2 = "none"
+	}
+	if writer, err = io.NewFastqWriter(output1, output2, comp); err != nil {
+		return
+	}
+
+	err = pipeline.Run(parser, threads, func(entry1, entry2 *fastq.FastqEntry) (bool, error) {
+		t.Trim(entry1)
+		if entry2 != nil {
+			t.Trim(entry2)
+		}
+		if !keepTrimmed(entry1, entry2, minLength) {
+			atomic.AddInt64(&discarded, 1)
+			return false, nil
+		}
+		return true, nil
+	}, func(entry1, entry2 *fastq.FastqEntry) error {
+		nbrecords++
+		return writer.Write(entry1, entry2)
+	})
+	if err != nil {
+		return
+	}
+
+	if err = writer.Close(); err != nil {
+		return
+	}
+	log.Printf("Wrote %d fastq records", nbrecords)
+	log.Printf("Discarded %d fastq records", discarded)
+	return
+}
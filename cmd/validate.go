@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/fredericlemoine/fastqutils/io"
+	"github.com/spf13/cobra"
+)
+
+var validateAll bool
+
+// validateCmd represents the validate command
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Checks that fastq file(s) are well formed",
+	Long: `Checks that fastq file(s) are well formed.
+
+For each record, checks that:
+- the header starts with '@'
+- the separator line starts with '+'
+- the sequence and the quality have the same length
+- the quality characters are between '!' and '~'
+- the record is complete
+- if paired, both reads have the same name, and both files have the same
+  number of records
+
+Problems are reported with the file name, record number and line number.
+By default, stops at the first problem. With --all, reports every problem.
+
+Exits with a non-zero status if at least one problem is found.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var parser *io.FastQParser
+		var verr *io.ValidationError
+		var perr *io.PairError
+		var err error
+
+		if interleaved {
+			parser, err = io.NewInterleavedParser(input1)
+		} else if input2 != "none" {
+			parser, err = io.NewPairedEndParser(input1, input2)
+		} else {
+			parser, err = io.NewSingleEndParser(input1)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer parser.Close()
+		parser.SetStrict(true)
+		parser.SetPairCheck(io.PAIRS_STRICT)
+
+		nbrecords := 0
+		problems := 0
+		for {
+			_, _, err = parser.NextEntry()
+			if err != nil {
+				if err.Error() == "EOF" {
+					break
+				}
+				if !errors.As(err, &verr) && !errors.As(err, &perr) {
+					parser.Close()
+					log.Fatal(err)
+				}
+				fmt.Fprintln(os.Stderr, err)
+				problems++
+				if !validateAll || errors.Is(err, io.ErrUnequalLengths) {
+					break
+				}
+				// Only valid records are counted
+				continue
+			}
+			nbrecords++
+		}
+
+		if problems > 0 {
+			fmt.Fprintf(os.Stderr, "%d problem(s) found, %d valid records\n", problems, nbrecords)
+			// os.Exit does not run deferred calls: the parser is
+			// closed first, so that dsrc subprocesses are terminated
+			parser.Close()
+			os.Exit(1)
+		}
+		fmt.Printf("OK: %d records\n", nbrecords)
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(validateCmd)
+	validateCmd.PersistentFlags().StringVarP(&input1, "input1", "1", "stdin", "First read fastq file")
+	validateCmd.PersistentFlags().StringVarP(&input2, "input2", "2", "none", "Second read fastq file")
+	validateCmd.PersistentFlags().BoolVar(&validateAll, "all", false, "Reports all problems instead of stopping at the first one")
+}
@@ -37,6 +37,23 @@ func genseq(length int) []byte {
 	return buf.Bytes()
 }
 
+// ReadID returns the identifier of a read name, which is common to
+// both reads of a pair: the leading '@' or '>', the comment (anything
+// after the first space or tab, such as the Casava 1.8 "1:N:0:ATCACG"
+// field) and the /1 or /2 suffix are removed.
+func ReadID(name []byte) []byte {
+	if len(name) > 0 && (name[0] == '@' || name[0] == '>') {
+		name = name[1:]
+	}
+	if i := bytes.IndexAny(name, " \t"); i >= 0 {
+		name = name[:i]
+	}
+	if n := len(name); n >= 2 && name[n-2] == '/' && (name[n-1] == '1' || name[n-1] == '2') {
+		name = name[:n-2]
+	}
+	return name
+}
+
 // Returns the nt
 func Nt(n int) (nt byte, err error) {
 	switch n {
@@ -56,18 +73,18 @@ func Nt(n int) (nt byte, err error) {
 	return
 }
 
-// Returns the nt
+// Returns the index of the nt (upper or lower case)
 func Index(b byte) (nt int, err error) {
 	switch b {
-	case 'A':
+	case 'A', 'a':
 		nt = 0
-	case 'C':
+	case 'C', 'c':
 		nt = 1
-	case 'G':
+	case 'G', 'g':
 		nt = 2
-	case 'T':
+	case 'T', 't':
 		nt = 3
-	case 'N':
+	case 'N', 'n':
 		nt = 4
 	default:
 		err = fmt.Errorf("No nucleotide %c", b)
@@ -82,3 +99,34 @@ func genqual(length int, minqual, maxqual int) []byte {
 	}
 	return buf.Bytes()
 }
+
+// ReverseComplement reverse complements the given sequence in place.
+// Nucleotides other than A, C, G and T (and their lower case versions)
+// are kept as is.
+func ReverseComplement(seq []byte) {
+	for i, j := 0, len(seq)-1; i <= j; i, j = i+1, j-1 {
+		seq[i], seq[j] = complement(seq[j]), complement(seq[i])
+	}
+}
+
+func complement(b byte) byte {
+	switch b {
+	case 'A':
+		return 'T'
+	case 'C':
+		return 'G'
+	case 'G':
+		return 'C'
+	case 'T':
+		return 'A'
+	case 'a':
+		return 't'
+	case 'c':
+		return 'g'
+	case 'g':
+		return 'c'
+	case 't':
+		return 'a'
+	}
+	return b
+}
@@ -1,11 +1,15 @@
 module github.com/fredericlemoine/fastqutils
 
-go 1.18
+go 1.22
 
 require (
 	github.com/biogo/hts v1.4.4
+	github.com/dsnet/compress v0.0.1
 	github.com/fredericlemoine/gostats v0.1.1
+	github.com/klauspost/compress v1.18.0
+	github.com/klauspost/pgzip v1.2.6
 	github.com/spf13/cobra v1.5.0
+	github.com/ulikunitz/xz v0.5.15
 )
 
 require (
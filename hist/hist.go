@@ -6,8 +6,12 @@ import (
 	"strings"
 )
 
+// IntHistogram is a histogram of integer values. The number of points
+// of every value is stored, and not every point, so that its memory
+// usage only depends on the number of distinct values.
 type IntHistogram struct {
-	points    []int
+	points    map[int]int // points[v]: number of points of value v
+	npoints   int
 	bins      []float64
 	counts    []int
 	maxcounts int
@@ -18,7 +22,8 @@ type IntHistogram struct {
 
 func NewIntHistogram(nbins int) *IntHistogram {
 	return &IntHistogram{
-		make([]int, 0, 10000),
+		make(map[int]int),
+		0,
 		make([]float64, nbins),
 		make([]int, nbins),
 		-1,
@@ -29,13 +34,22 @@ func NewIntHistogram(nbins int) *IntHistogram {
 }
 
 func (ih *IntHistogram) AddPoint(p int) {
-	if len(ih.points) == 0 || p < ih.min {
+	ih.AddPoints(p, 1)
+}
+
+// AddPoints adds n points of value p.
+func (ih *IntHistogram) AddPoints(p, n int) {
+	if n <= 0 {
+		return
+	}
+	if ih.npoints == 0 || p < ih.min {
 		ih.min = p
 	}
-	if len(ih.points) == 0 || p > ih.max {
+	if ih.npoints == 0 || p > ih.max {
 		ih.max = p
 	}
-	ih.points = append(ih.points, p)
+	ih.points[p] += n
+	ih.npoints += n
 }
 
 func (ih *IntHistogram) Draw(width int) string {
@@ -69,12 +83,27 @@ func (ih *IntHistogram) Draw(width int) string {
 	return sb.String()
 }
 
+// Bins returns the middle of every bin of the histogram, and the
+// number of points in every bin.
+func (ih *IntHistogram) Bins() (bins []float64, counts []int) {
+	ih.updateBins()
+	bins = append([]float64(nil), ih.bins...)
+	counts = append([]int(nil), ih.counts...)
+	return
+}
+
 func (ih *IntHistogram) updateBins() {
-	for i, p := range ih.points {
+	for b := range ih.counts {
+		ih.counts[b] = 0
+	}
+	for p, n := range ih.points {
 		bin := int(float64((ih.nbins-1)*(p-ih.min)) / math.Max(float64(ih.max-ih.min), 1.0))
-		ih.counts[bin]++
-		if i == 0 || ih.counts[bin] > ih.maxcounts {
-			ih.maxcounts = ih.counts[bin]
+		ih.counts[bin] += n
+	}
+	ih.maxcounts = -1
+	if ih.npoints > 0 {
+		for _, c := range ih.counts {
+			ih.maxcounts = max(ih.maxcounts, c)
 		}
 	}
 	for b := range ih.counts {
@@ -84,3 +113,16 @@ func (ih *IntHistogram) updateBins() {
 		ih.bins[b] = binmid
 	}
 }
+
+// Merge returns a new histogram, with the number of bins of ih,
+// containing the points of ih and of o.
+func (ih *IntHistogram) Merge(o *IntHistogram) *IntHistogram {
+	m := NewIntHistogram(ih.nbins)
+	for p, n := range ih.points {
+		m.AddPoints(p, n)
+	}
+	for p, n := range o.points {
+		m.AddPoints(p, n)
+	}
+	return m
+}
@@ -0,0 +1,209 @@
+package io
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/biogo/hts/bam"
+	"github.com/biogo/hts/sam"
+	"github.com/fredericlemoine/fastqutils/fastq"
+)
+
+// BamParser is a Reader of (typically unaligned) BAM files.
+//
+// Secondary and supplementary alignments are skipped. Reads flagged
+// as paired are expected to be followed by their mate, as in files
+// produced by `fastqutils tobam`. Reads aligned on the reverse strand
+// are reverse complemented back to their original orientation.
+//
+// Qualities are returned Phred+33 encoded.
+type BamParser struct {
+	reader *bam.Reader
+	file   *os.File
+}
+
+func NewBamParser(file string) (bp *BamParser, err error) {
+	var fi *os.File
+	var br *bam.Reader
+
+	if file == "stdin" || file == "-" {
+		fi = os.Stdin
+	} else {
+		if fi, err = os.Open(file); err != nil {
+			return
+		}
+	}
+	if br, err = bam.NewReader(fi, 1); err != nil {
+		if fi != os.Stdin {
+			fi.Close()
+		}
+		return
+	}
+	bp = &BamParser{
+		reader: br,
+		file:   fi,
+	}
+	return
+}
+
+// Close closes the bam reader and the underlying file.
+func (p *BamParser) Close() (err error) {
+	err = p.reader.Close()
+	if p.file != os.Stdin {
+		if cerr := p.file.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return
+}
+
+// nextPrimary returns the next record that is neither secondary nor
+// supplementary.
+func (p *BamParser) nextPrimary() (rec *sam.Record, err error) {
+	for {
+		if rec, err = p.reader.Read(); err != nil {
+			return
+		}
+		if rec.Flags&(sam.Secondary|sam.Supplementary) == 0 {
+			return
+		}
+	}
+}
+
+// Next returns the next entries:
+// If the read is paired, returns the read and its mate
+// Otherwise: returns 1 entry and nil
+func (p *BamParser) Next() (entry1 *fastq.FastqEntry, entry2 *fastq.FastqEntry, err error) {
+	var rec1, rec2 *sam.Record
+
+	if rec1, err = p.nextPrimary(); err != nil {
+		return
+	}
+	entry1 = bamToEntry(rec1)
+	if rec1.Flags&sam.Paired != 0 {
+		if rec2, err = p.nextPrimary(); err != nil {
+			if err == io.EOF {
+				err = fmt.Errorf("bam: mate of read %s is missing", rec1.Name)
+			}
+			return
+		}
+		if rec2.Flags&sam.Read1 != 0 && rec1.Flags&sam.Read2 != 0 {
+			rec1, rec2 = rec2, rec1
+			entry1 = bamToEntry(rec1)
+		}
+		entry2 = bamToEntry(rec2)
+	}
+	return
+}
+
+// bamToEntry converts a sam record into a fastq entry
+func bamToEntry(rec *sam.Record) *fastq.FastqEntry {
+	var name, seq, qual []byte
+
+	name = []byte(rec.Name)
+	if len(name) == 0 || name[0] != '@' {
+		name = append([]byte{'@'}, name...)
+	}
+	seq = rec.Seq.Expand()
+	if len(rec.Qual) > 0 && rec.Qual[0] != 0xff {
+		qual = make([]byte, len(rec.Qual))
+		for i, q := range rec.Qual {
+			qual[i] = q + 33
+		}
+	}
+	if rec.Flags&sam.Reverse != 0 {
+		fastq.ReverseComplement(seq)
+		for i, j := 0, len(qual)-1; i < j; i, j = i+1, j-1 {
+			qual[i], qual[j] = qual[j], qual[i]
+		}
+	}
+	return &fastq.FastqEntry{
+		Name:     name,
+		Sequence: seq,
+		Quality:  qual,
+	}
+}
+
+// BamWriter is a Writer producing an unaligned BAM file.
+// Both reads of a pair are written to the same file, flagged
+// as first and second reads.
+type BamWriter struct {
+	writer *bam.Writer
+	file   *os.File
+	offset int
+}
+
+// NewBamWriter creates an unaligned BAM file. offset is the quality
+// encoding offset of the entries that will be written (see
+// stats.Encoding.Offset), it is subtracted to store raw Phred scores.
+func NewBamWriter(file string, offset int) (bw *BamWriter, err error) {
+	var fi *os.File
+	var header *sam.Header
+	var w *bam.Writer
+
+	if header, err = sam.NewHeader(nil, nil); err != nil {
+		return
+	}
+	if file == "stdout" || file == "-" {
+		fi = os.Stdout
+	} else {
+		if fi, err = os.Create(file); err != nil {
+			return
+		}
+	}
+	// BGZF blocks are compressed in parallel (see SetThreads)
+	if w, err = bam.NewWriter(fi, header, threads); err != nil {
+		if fi != os.Stdout {
+			fi.Close()
+		}
+		return
+	}
+	bw = &BamWriter{
+		writer: w,
+		file:   fi,
+		offset: offset,
+	}
+	return
+}
+
+func (bw *BamWriter) writeRecord(entry *fastq.FastqEntry, flags sam.Flags) (err error) {
+	var rec *sam.Record
+	// We encode the quality with the right offset
+	qual := make([]byte, len(entry.Quality))
+	for i, q := range entry.Quality {
+		qual[i] = byte(int(q) - bw.offset)
+	}
+	if rec, err = sam.NewRecord(string(entry.Name), nil, nil, -1, -1, 0, byte(0), []sam.CigarOp{}, entry.Sequence, qual, []sam.Aux{}); err != nil {
+		return
+	}
+	rec.Flags = flags
+	return bw.writer.Write(rec)
+}
+
+// Write writes entry1 as first read, and entry2 (if not nil) as
+// second read.
+func (bw *BamWriter) Write(entry1 *fastq.FastqEntry, entry2 *fastq.FastqEntry) (err error) {
+	flag1 := sam.Read1 | sam.Unmapped
+	if entry2 != nil {
+		flag1 = flag1 | sam.Paired | sam.MateUnmapped
+	}
+	if err = bw.writeRecord(entry1, flag1); err != nil {
+		return
+	}
+	if entry2 != nil {
+		err = bw.writeRecord(entry2, sam.Read2|sam.Unmapped|sam.Paired|sam.MateUnmapped)
+	}
+	return
+}
+
+// Close finalizes the bam file and closes it.
+func (bw *BamWriter) Close() (err error) {
+	if err = bw.writer.Close(); err != nil {
+		return
+	}
+	if bw.file != os.Stdout {
+		err = bw.file.Close()
+	}
+	return
+}
@@ -0,0 +1,175 @@
+package io
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/biogo/hts/bgzf"
+)
+
+// GziExtension is the extension of BGZF index files, appended to the
+// name of the BGZF file.
+const GziExtension = ".gzi"
+
+// GziEntry is the start of a BGZF block, in the compressed file and
+// in the uncompressed data.
+type GziEntry struct {
+	Compressed   uint64
+	Uncompressed uint64
+}
+
+// GziIndex is an index of the blocks of a BGZF file, in the .gzi
+// format used by htslib (bgzip -i): the number of entries followed
+// by the entries, as little-endian uint64, the first block (at offset
+// 0) being omitted.
+type GziIndex struct {
+	Entries []GziEntry
+}
+
+// Write writes the index in .gzi format.
+func (idx *GziIndex) Write(w io.Writer) (err error) {
+	buf := make([]byte, 8, 8+16*len(idx.Entries))
+	binary.LittleEndian.PutUint64(buf, uint64(len(idx.Entries)))
+	for _, e := range idx.Entries {
+		buf = binary.LittleEndian.AppendUint64(buf, e.Compressed)
+		buf = binary.LittleEndian.AppendUint64(buf, e.Uncompressed)
+	}
+	_, err = w.Write(buf)
+	return
+}
+
+// ReadGzi reads a .gzi index file.
+func ReadGzi(file string) (idx *GziIndex, err error) {
+	var fi *os.File
+	var n uint64
+
+	if fi, err = os.Open(file); err != nil {
+		return
+	}
+	defer fi.Close()
+	r := bufio.NewReader(fi)
+	if err = binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return nil, fmt.Errorf("%s: reading gzi index: %w", file, err)
+	}
+	idx = &GziIndex{Entries: make([]GziEntry, n)}
+	if err = binary.Read(r, binary.LittleEndian, idx.Entries); err != nil {
+		return nil, fmt.Errorf("%s: reading gzi index: %w", file, err)
+	}
+	return
+}
+
+// Offset returns the BGZF virtual offset corresponding to the
+// given offset in the uncompressed data.
+func (idx *GziIndex) Offset(off uint64) bgzf.Offset {
+	// First block that starts after off
+	i := sort.Search(len(idx.Entries), func(i int) bool {
+		return idx.Entries[i].Uncompressed > off
+	})
+	if i == 0 {
+		return bgzf.Offset{File: 0, Block: uint16(off)}
+	}
+	e := idx.Entries[i-1]
+	return bgzf.Offset{File: int64(e.Compressed), Block: uint16(off - e.Uncompressed)}
+}
+
+// blockIndexer is an io.Writer, placed between a bgzf.Writer and its
+// output, that indexes the BGZF blocks written through it. It relies
+// on bgzf.Writer writing each block with a single Write call.
+type blockIndexer struct {
+	w     io.Writer
+	coff  uint64 // Compressed offset of the next block
+	uoff  uint64 // Uncompressed offset of the next block
+	index GziIndex
+}
+
+func (bi *blockIndexer) Write(b []byte) (n int, err error) {
+	n, err = bi.w.Write(b)
+	if err != nil || len(b) < 4 {
+		return
+	}
+	// The uncompressed size of the block is in the last 4 bytes
+	// of the gzip member (ISIZE). Empty blocks (EOF marker) are not
+	// indexed.
+	if isize := binary.LittleEndian.Uint32(b[len(b)-4:]); isize > 0 {
+		if bi.coff > 0 {
+			bi.index.Entries = append(bi.index.Entries, GziEntry{bi.coff, bi.uoff})
+		}
+		bi.uoff += uint64(isize)
+	}
+	bi.coff += uint64(n)
+	return
+}
+
+// bgzfCloser finalizes a BGZF stream, writes its index if needed, and
+// closes the underlying file.
+type bgzfCloser struct {
+	bw      *bgzf.Writer
+	indexer *blockIndexer
+	file    *os.File
+	gzi     string // Name of the index file, "" for no index
+}
+
+func (c *bgzfCloser) Close() (err error) {
+	var fi *os.File
+	if err = c.bw.Close(); err != nil {
+		return
+	}
+	if c.gzi != "" {
+		if fi, err = os.Create(c.gzi); err != nil {
+			return
+		}
+		if err = c.indexer.index.Write(fi); err != nil {
+			fi.Close()
+			return
+		}
+		if err = fi.Close(); err != nil {
+			return
+		}
+	}
+	return c.file.Close()
+}
+
+// bgzfWriter returns a BGZF writer to fi, which writes the .gzi
+// index of the file to the file named gzi when closed (no index is
+// written if gzi is empty). The returned Closer also closes fi.
+func bgzfWriter(fi *os.File, gzi string) (w io.Writer, closer io.Closer) {
+	indexer := &blockIndexer{w: fi}
+	bw := bgzf.NewWriter(indexer, threads)
+	return bw, &bgzfCloser{
+		bw:      bw,
+		indexer: indexer,
+		file:    fi,
+		gzi:     gzi,
+	}
+}
+
+// OpenBgzfAt opens a BGZF compressed file, and positions the returned
+// reader at the given offset of the uncompressed data, using the
+// .gzi index of the file (see GziExtension). The returned Closer must
+// be called once reading is finished.
+func OpenBgzfAt(file string, off uint64) (reader *bufio.Reader, closer io.Closer, err error) {
+	var idx *GziIndex
+	var fi *os.File
+	var br *bgzf.Reader
+
+	if idx, err = ReadGzi(file + GziExtension); err != nil {
+		return
+	}
+	if fi, err = os.Open(file); err != nil {
+		return
+	}
+	if br, err = bgzf.NewReader(fi, 1); err != nil {
+		fi.Close()
+		return
+	}
+	if err = br.Seek(idx.Offset(off)); err != nil {
+		br.Close()
+		fi.Close()
+		return
+	}
+	return bufio.NewReader(br), &multiReadCloser{[]io.Closer{br, fi}}, nil
+}
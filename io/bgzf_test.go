@@ -0,0 +1,51 @@
+package io
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBgzfIndex(t *testing.T) {
+	var sb strings.Builder
+	for i := 0; i < 20000; i++ {
+		fmt.Fprintf(&sb, "@read%d\nACGTACGTACGTACGTACGT\n+\nIIIIIIIIIIIIIIIIIIII\n", i)
+	}
+	data := sb.String()
+
+	file := filepath.Join(t.TempDir(), "reads.fq")
+	w, closer, err := GetWriter(file, BGZF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteString(data)
+	if err = closer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	file += CompressionExtension(BGZF)
+	idx, err := ReadGzi(file + GziExtension)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(idx.Entries) < 10 {
+		t.Fatalf("expected several blocks in the index, got %d", len(idx.Entries))
+	}
+
+	for _, off := range []uint64{0, 1, idx.Entries[3].Uncompressed, idx.Entries[3].Uncompressed - 1, uint64(len(data) - 10)} {
+		r, c, err := OpenBgzfAt(file, off)
+		if err != nil {
+			t.Fatalf("offset %d: %v", off, err)
+		}
+		got, err := io.ReadAll(r)
+		c.Close()
+		if err != nil {
+			t.Fatalf("offset %d: %v", off, err)
+		}
+		if string(got) != data[off:] {
+			t.Errorf("offset %d: read data differs from the original one", off)
+		}
+	}
+}
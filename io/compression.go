@@ -0,0 +1,275 @@
+package io
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/biogo/hts/bgzf"
+	dbzip2 "github.com/dsnet/compress/bzip2"
+	"github.com/klauspost/compress/zstd"
+	"github.com/klauspost/pgzip"
+	"github.com/ulikunitz/xz"
+)
+
+// Compression formats of input and output files
+const (
+	PLAIN = iota
+	GZIP
+	BGZF
+	BZIP2
+	XZ
+	ZSTD
+	DSRC
+)
+
+// Magic numbers of compressed formats
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+	xzMagic    = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+	zstdMagic  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// threads is the number of goroutines used to compress and decompress
+// gzip and BGZF data (see SetThreads).
+var threads = 1
+
+// pgzipBlockSize is the size of the blocks compressed in parallel by
+// pgzip.
+const pgzipBlockSize = 1 << 20
+
+// SetThreads sets the number of goroutines used to compress gzip and
+// BGZF output, and to decompress gzip and BGZF input. With 1 thread
+// (default), the standard compress/gzip package is used. With more,
+// gzip output is compressed by blocks in parallel (klauspost/pgzip),
+// gzip input is decompressed ahead of reading in a separate goroutine,
+// and BGZF blocks are compressed and decompressed in parallel.
+func SetThreads(n int) {
+	if n < 1 {
+		n = 1
+	}
+	threads = n
+}
+
+// magicLength is the number of bytes needed by DetectCompression
+const magicLength = 16
+
+// dsrcHeaderLength is the length of the header of DSRC archives
+const dsrcHeaderLength = 16
+
+// isDsrcHeader returns true if header looks like the header of a DSRC
+// archive of size bytes (size is negative if unknown).
+//
+// DSRC archives do not start with a magic number, but with the offset
+// and the size of the archive footer, as two little-endian uint64. The
+// footer follows the compressed blocks, and ends the archive. The
+// header is recognized if the offset is after the header, the footer
+// is not empty and, if the size of the archive is known, if the footer
+// ends the archive. If it is not, the high bytes of both values (of
+// archives smaller than 1 TB) must be 0, which never occurs in text.
+func isDsrcHeader(header []byte, size int64) bool {
+	if len(header) < dsrcHeaderLength {
+		return false
+	}
+	offset := binary.LittleEndian.Uint64(header[:8])
+	footer := binary.LittleEndian.Uint64(header[8:16])
+	if offset < dsrcHeaderLength || footer == 0 {
+		return false
+	}
+	if size >= 0 {
+		return offset+footer == uint64(size)
+	}
+	return offset < 1<<40 && footer < 1<<40
+}
+
+// DetectCompression returns the compression format of data starting
+// with the given header bytes (at least the first 16 bytes of the data,
+// if available), from its magic number.
+//
+// DSRC archives do not start with a magic number: they are detected
+// from the structure of their header (see isDsrcHeader). DSRC archives
+// can only be decompressed from files, which are also recognized by
+// their .dsrc extension (see dsrc.IsDsrcFile).
+func DetectCompression(header []byte) int {
+	switch {
+	case bytes.HasPrefix(header, gzipMagic):
+		// BGZF is gzip, with an extra field (FLG.FEXTRA)
+		// whose first subfield is "BC"
+		if len(header) >= 14 && header[3]&0x04 != 0 && header[12] == 'B' && header[13] == 'C' {
+			return BGZF
+		}
+		return GZIP
+	case bytes.HasPrefix(header, bzip2Magic):
+		return BZIP2
+	case bytes.HasPrefix(header, xzMagic):
+		return XZ
+	case bytes.HasPrefix(header, zstdMagic):
+		return ZSTD
+	case isDsrcHeader(header, -1):
+		return DSRC
+	}
+	return PLAIN
+}
+
+// CompressionToString returns the name of the given compression
+// format.
+func CompressionToString(comp int) string {
+	switch comp {
+	case PLAIN:
+		return "none"
+	case GZIP:
+		return "gzip"
+	case BGZF:
+		return "bgzf"
+	case BZIP2:
+		return "bzip2"
+	case XZ:
+		return "xz"
+	case ZSTD:
+		return "zstd"
+	case DSRC:
+		return "dsrc"
+	}
+	return "unknown"
+}
+
+// CompressionFromString returns the compression format corresponding
+// to the given name (none, gzip, bgzf, bzip2, xz, zstd or dsrc).
+func CompressionFromString(comp string) (c int, err error) {
+	switch comp {
+	case "none":
+		c = PLAIN
+	case "gzip":
+		c = GZIP
+	case "bgzf":
+		c = BGZF
+	case "bzip2":
+		c = BZIP2
+	case "xz":
+		c = XZ
+	case "zstd":
+		c = ZSTD
+	case "dsrc":
+		c = DSRC
+	default:
+		err = fmt.Errorf("this compression format does not exist : %s, possible values are : none, gzip, bgzf, bzip2, xz, zstd, dsrc", comp)
+	}
+	return
+}
+
+// CompressionExtension returns the file extension of the given
+// compression format ("" for PLAIN).
+func CompressionExtension(comp int) string {
+	switch comp {
+	case GZIP, BGZF:
+		return ".gz"
+	case BZIP2:
+		return ".bz2"
+	case XZ:
+		return ".xz"
+	case ZSTD:
+		return ".zst"
+	case DSRC:
+		return ".dsrc"
+	}
+	return ""
+}
+
+// closerFunc turns a Close method without return value into an
+// io.Closer.
+type closerFunc func()
+
+func (f closerFunc) Close() error {
+	f()
+	return nil
+}
+
+// decompress sniffs the compression format of r, and returns a reader
+// of the decompressed data, plus a Closer to release the decompressor
+// (nil if there is nothing to release).
+func decompress(r *bufio.Reader) (dr io.Reader, closer io.Closer, err error) {
+	// Peek returns an error if the input is shorter than magicLength,
+	// which is not a problem here
+	header, _ := r.Peek(magicLength)
+
+	switch comp := DetectCompression(header); comp {
+	case PLAIN:
+		dr = r
+	case BGZF:
+		if threads > 1 {
+			var br *bgzf.Reader
+			if br, err = bgzf.NewReader(r, threads); err != nil {
+				return
+			}
+			dr, closer = br, br
+			return
+		}
+		// BGZF files are multi-member gzip files, which gzip.Reader
+		// reads as a single stream
+		fallthrough
+	case GZIP:
+		if threads > 1 {
+			var pr *pgzip.Reader
+			if pr, err = pgzip.NewReaderN(r, pgzipBlockSize, threads); err != nil {
+				return
+			}
+			dr, closer = pr, pr
+			return
+		}
+		var gr *gzip.Reader
+		if gr, err = gzip.NewReader(r); err != nil {
+			return
+		}
+		dr, closer = gr, gr
+	case BZIP2:
+		dr = bzip2.NewReader(r)
+	case XZ:
+		if dr, err = xz.NewReader(r); err != nil {
+			return
+		}
+	case ZSTD:
+		var zr *zstd.Decoder
+		if zr, err = zstd.NewReader(r); err != nil {
+			return
+		}
+		dr, closer = zr, closerFunc(zr.Close)
+	case DSRC:
+		err = fmt.Errorf("dsrc compressed input can only be read from a file, and not from a stream such as stdin")
+	default:
+		err = fmt.Errorf("%s compressed input is not supported", CompressionToString(comp))
+	}
+	return
+}
+
+// compressor returns a writer compressing data to w in the given
+// format. Its Close method finalizes the compressed stream, but does
+// not close w.
+func compressor(w io.Writer, comp int) (cw io.WriteCloser, err error) {
+	switch comp {
+	case GZIP:
+		if threads > 1 {
+			pw := pgzip.NewWriter(w)
+			if err = pw.SetConcurrency(pgzipBlockSize, threads); err != nil {
+				return
+			}
+			cw = pw
+		} else {
+			cw = gzip.NewWriter(w)
+		}
+	case XZ:
+		cw, err = xz.NewWriter(w)
+	case ZSTD:
+		cw, err = zstd.NewWriter(w)
+	case BZIP2:
+		// The standard library only decompresses bzip2
+		cw, err = dbzip2.NewWriter(w, nil)
+	default:
+		err = fmt.Errorf("unsupported output compression : %s", CompressionToString(comp))
+	}
+	return
+}
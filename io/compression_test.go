@@ -0,0 +1,250 @@
+package io
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/biogo/hts/bgzf"
+	"github.com/fredericlemoine/fastqutils/fastq"
+)
+
+// dsrcHeader returns the header of a DSRC archive whose footer starts
+// at offset, and is size bytes long.
+func dsrcHeader(offset, size uint64) []byte {
+	header := make([]byte, 16)
+	binary.LittleEndian.PutUint64(header, offset)
+	binary.LittleEndian.PutUint64(header[8:], size)
+	return header
+}
+
+func TestDetectCompression(t *testing.T) {
+	const data = "@read1\nACGT\n+\nIIII\n"
+	var gz, bgz bytes.Buffer
+
+	gw := gzip.NewWriter(&gz)
+	gw.Write([]byte(data))
+	gw.Close()
+	bw := bgzf.NewWriter(&bgz, 1)
+	bw.Write([]byte(data))
+	bw.Close()
+
+	cases := []struct {
+		name   string
+		header []byte
+		want   int
+	}{
+		{"plain", []byte(data), PLAIN},
+		{"empty", []byte{}, PLAIN},
+		{"gzip", gz.Bytes(), GZIP},
+		{"bgzf", bgz.Bytes(), BGZF},
+		{"bzip2", []byte("BZh91AY&SY"), BZIP2},
+		{"xz", []byte{0xfd, '7', 'z', 'X', 'Z', 0x00, 0x00}, XZ},
+		{"zstd", []byte{0x28, 0xb5, 0x2f, 0xfd, 0x00}, ZSTD},
+		{"dsrc", dsrcHeader(1000, 24), DSRC},
+		{"short dsrc", dsrcHeader(1000, 24)[:10], PLAIN},
+	}
+	for _, c := range cases {
+		if got := DetectCompression(c.header); got != c.want {
+			t.Errorf("%s: got %s, want %s", c.name, CompressionToString(got), CompressionToString(c.want))
+		}
+	}
+
+	// gzip data in a file without .gz extension
+	file := filepath.Join(t.TempDir(), "reads.fq")
+	if err := os.WriteFile(file, bgz.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewSingleEndParser(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if entry, _, err := r.Next(); err != nil || string(entry.Sequence) != "ACGT" {
+		t.Errorf("reading bgzf file without extension: got %v, %v", entry, err)
+	}
+}
+
+func TestDetectDsrc(t *testing.T) {
+	archive := append(dsrcHeader(100, 28), make([]byte, 112)...)
+
+	// Streams cannot be decompressed
+	if _, _, err := decompress(bufio.NewReader(bytes.NewReader(archive))); err == nil || !strings.Contains(err.Error(), "dsrc") {
+		t.Errorf("dsrc stream: got error %v, want a dsrc error", err)
+	}
+
+	// Files without the .dsrc extension are given to dsrc, unless they
+	// are truncated
+	dir := t.TempDir()
+	t.Setenv("DSRC_BIN", filepath.Join(dir, "no-dsrc"))
+	for _, c := range []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"archive", archive, "no-dsrc"},
+		{"truncated", archive[:80], "truncated"},
+	} {
+		file := filepath.Join(dir, c.name+".fq")
+		if err := os.WriteFile(file, c.data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		r, closer, err := GetReader(file)
+		if err == nil {
+			// The dsrc executable does not exist: reading fails
+			_, err = r.ReadByte()
+			if cerr := closer.Close(); err == nil {
+				err = cerr
+			}
+		}
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: got error %v, want an error containing %q", c.name, err, c.want)
+		}
+	}
+}
+
+func TestCompressionRoundTrip(t *testing.T) {
+	const data = "@read1\nACGT\n+\nIIII\n"
+	dir := t.TempDir()
+	for _, comp := range []int{PLAIN, GZIP, BZIP2, XZ, ZSTD} {
+		file := filepath.Join(dir, "reads_"+CompressionToString(comp))
+		w, closer, err := GetWriter(file, comp)
+		if err != nil {
+			t.Fatalf("%s: %v", CompressionToString(comp), err)
+		}
+		w.WriteString(data)
+		if err = closer.Close(); err != nil {
+			t.Fatalf("%s: %v", CompressionToString(comp), err)
+		}
+
+		b, err := os.ReadFile(file + CompressionExtension(comp))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := DetectCompression(b); got != comp {
+			t.Errorf("%s: detected %s", CompressionToString(comp), CompressionToString(got))
+		}
+		r, rcloser, err := GetReader(file + CompressionExtension(comp))
+		if err != nil {
+			t.Fatalf("%s: %v", CompressionToString(comp), err)
+		}
+		name, seq, qual, err := Readln(r)
+		if err != nil || string(name) != "@read1" || string(seq) != "ACGT" || string(qual) != "IIII" {
+			t.Errorf("%s: got %s %s %s %v", CompressionToString(comp), name, seq, qual, err)
+		}
+		if rcloser != nil {
+			rcloser.Close()
+		}
+	}
+}
+
+// benchData returns about 16MB of FASTQ data
+func benchData() []byte {
+	var sb strings.Builder
+	for i := 0; sb.Len() < 16<<20; i++ {
+		e := fastq.GenFastQEntry(150, i, 35, 74)
+		fmt.Fprintf(&sb, "%s\n%s\n+\n%s\n", e.Name, e.Sequence, e.Quality)
+	}
+	return []byte(sb.String())
+}
+
+func TestParallelCompression(t *testing.T) {
+	data := benchData()[:1<<22]
+	dir := t.TempDir()
+	defer SetThreads(1)
+	for _, comp := range []int{GZIP, BGZF} {
+		for _, th := range []int{1, 4} {
+			SetThreads(th)
+			file := filepath.Join(dir, fmt.Sprintf("%s_%d", CompressionToString(comp), th))
+			w, closer, err := GetWriter(file, comp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.Write(data)
+			if err = closer.Close(); err != nil {
+				t.Fatal(err)
+			}
+			for _, rth := range []int{1, 4} {
+				SetThreads(rth)
+				r, rcloser, err := GetReader(file + CompressionExtension(comp))
+				if err != nil {
+					t.Fatal(err)
+				}
+				got, err := io.ReadAll(r)
+				rcloser.Close()
+				if err != nil || !bytes.Equal(got, data) {
+					t.Errorf("%s written with %d threads, read with %d threads: data differs (%v)", CompressionToString(comp), th, rth, err)
+				}
+			}
+		}
+	}
+}
+
+// BenchmarkCompress compares single-threaded compression (compress/gzip
+// and 1 BGZF compressor) with parallel compression.
+func BenchmarkCompress(b *testing.B) {
+	data := benchData()
+	dir := b.TempDir()
+	defer SetThreads(1)
+	for _, comp := range []int{GZIP, BGZF} {
+		for _, th := range []int{1, 4} {
+			b.Run(fmt.Sprintf("%s/threads=%d", CompressionToString(comp), th), func(b *testing.B) {
+				SetThreads(th)
+				b.SetBytes(int64(len(data)))
+				for i := 0; i < b.N; i++ {
+					w, closer, err := GetWriter(filepath.Join(dir, "bench"), comp)
+					if err != nil {
+						b.Fatal(err)
+					}
+					w.Write(data)
+					if err = closer.Close(); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkDecompress compares single-threaded decompression
+// (compress/gzip) with parallel decompression.
+func BenchmarkDecompress(b *testing.B) {
+	data := benchData()
+	dir := b.TempDir()
+	defer SetThreads(1)
+	for _, comp := range []int{GZIP, BGZF} {
+		SetThreads(4)
+		file := filepath.Join(dir, CompressionToString(comp))
+		w, closer, err := GetWriter(file, comp)
+		if err != nil {
+			b.Fatal(err)
+		}
+		w.Write(data)
+		if err = closer.Close(); err != nil {
+			b.Fatal(err)
+		}
+		for _, th := range []int{1, 4} {
+			b.Run(fmt.Sprintf("%s/threads=%d", CompressionToString(comp), th), func(b *testing.B) {
+				SetThreads(th)
+				b.SetBytes(int64(len(data)))
+				for i := 0; i < b.N; i++ {
+					r, rcloser, err := GetReader(file + CompressionExtension(comp))
+					if err != nil {
+						b.Fatal(err)
+					}
+					if _, err = io.Copy(io.Discard, r); err != nil {
+						b.Fatal(err)
+					}
+					rcloser.Close()
+				}
+			})
+		}
+	}
+}
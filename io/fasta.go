@@ -0,0 +1,141 @@
+package io
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/fredericlemoine/fastqutils/fastq"
+)
+
+// FastaParser is a Reader of FASTA file(s).
+//
+// To be interchangeable with FASTQ records, the names of
+// returned entries start with '@' instead of '>', and their
+// Quality is nil.
+type FastaParser struct {
+	reader1 *bufio.Reader // First read file
+	reader2 *bufio.Reader // paired read file (if any, nil otherwise)
+	closer1 io.Closer     // non-nil when reader1 is backed by a resource that must be released
+	closer2 io.Closer     // same, for reader2
+}
+
+func NewSingleEndFastaParser(file string) (fp *FastaParser, err error) {
+	fp = &FastaParser{}
+	if fp.reader1, fp.closer1, err = GetReader(file); err != nil {
+		return nil, err
+	}
+	return
+}
+
+func NewPairedEndFastaParser(read1, read2 string) (fp *FastaParser, err error) {
+	fp = &FastaParser{}
+	if fp.reader1, fp.closer1, err = GetReader(read1); err != nil {
+		return nil, err
+	}
+	if fp.reader2, fp.closer2, err = GetReader(read2); err != nil {
+		fp.Close()
+		return nil, err
+	}
+	return
+}
+
+// NewInterleavedFastaParser returns a parser of an interleaved
+// paired-end FASTA file, whose consecutive records are returned as
+// pairs.
+func NewInterleavedFastaParser(file string) (fp *FastaParser, err error) {
+	fp = &FastaParser{}
+	if fp.reader1, fp.closer1, err = GetReader(file); err != nil {
+		return nil, err
+	}
+	fp.reader2 = fp.reader1
+	return
+}
+
+// Close releases any resources backing the parser's underlying readers.
+func (p *FastaParser) Close() (err error) {
+	if p.closer1 != nil {
+		if cerr := p.closer1.Close(); cerr != nil {
+			err = cerr
+		}
+	}
+	if p.closer2 != nil {
+		if cerr := p.closer2.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return
+}
+
+// readFasta reads the next FASTA record from the input buffered
+// reader. The sequence may be wrapped over several lines, and
+// Windows line endings (\r\n) are accepted.
+func readFasta(r *bufio.Reader) (entry *fastq.FastqEntry, err error) {
+	var name, seq, line, next []byte
+
+	if name, err = readLine(r); err != nil {
+		return
+	}
+	if len(name) == 0 || name[0] != '>' {
+		err = fmt.Errorf("fasta header does not start with '>' : %s", name)
+		return
+	}
+	name[0] = '@'
+	// Sequence lines, up to the next header
+	for {
+		if next, err = r.Peek(1); err != nil || next[0] == '>' {
+			break
+		}
+		if line, err = readLine(r); err != nil {
+			break
+		}
+		seq = append(seq, line...)
+	}
+	if err != nil && err != io.EOF {
+		return
+	}
+	err = nil
+	entry = &fastq.FastqEntry{
+		Name:     name,
+		Sequence: seq,
+	}
+	return
+}
+
+// Next returns the next entries:
+// If paired end returns 2 fasta entries
+// If single end: returns 1 entry and nil
+func (p *FastaParser) Next() (entry1 *fastq.FastqEntry, entry2 *fastq.FastqEntry, err error) {
+	if entry1, err = readFasta(p.reader1); err != nil {
+		return
+	}
+	if p.reader2 != nil {
+		if entry2, err = readFasta(p.reader2); err == io.EOF {
+			err = fmt.Errorf("fasta: missing second read of %s", entry1.Name)
+		}
+	}
+	return
+}
+
+// FastaWriter is a Writer producing FASTA file(s).
+type FastaWriter struct {
+	*pairWriter
+}
+
+// NewFastaWriter returns a Writer that writes first reads in FASTA
+// format to output1 and second reads to output2. If output2 is "none",
+// second reads are not written. comp is the output compression
+// format, as in GetWriter.
+//
+// DSRC compresses raw FASTQ streams and does not apply to FASTA.
+func NewFastaWriter(output1, output2 string, comp int) (fw *FastaWriter, err error) {
+	var pw *pairWriter
+	if comp == DSRC {
+		return nil, fmt.Errorf("dsrc compression only applies to fastq files")
+	}
+	if pw, err = newPairWriter(output1, output2, comp, WriteEntryFasta); err != nil {
+		return
+	}
+	fw = &FastaWriter{pw}
+	return
+}
@@ -0,0 +1,73 @@
+package io
+
+import (
+	"io"
+
+	"github.com/fredericlemoine/fastqutils/fastq"
+)
+
+// MemoryReader is a Reader over in-memory entries, typically used
+// in tests or when fastqutils is used as a library.
+type MemoryReader struct {
+	entries1 []*fastq.FastqEntry
+	entries2 []*fastq.FastqEntry
+	cur      int
+}
+
+// NewMemoryReader returns a Reader over entries1 and, if it is
+// not nil, the paired entries2. Both slices must have the same length.
+func NewMemoryReader(entries1, entries2 []*fastq.FastqEntry) *MemoryReader {
+	return &MemoryReader{
+		entries1: entries1,
+		entries2: entries2,
+	}
+}
+
+// Next returns the next entries, or io.EOF when all entries have been
+// returned.
+func (mr *MemoryReader) Next() (entry1 *fastq.FastqEntry, entry2 *fastq.FastqEntry, err error) {
+	if mr.cur >= len(mr.entries1) {
+		err = io.EOF
+		return
+	}
+	entry1 = mr.entries1[mr.cur]
+	if mr.entries2 != nil {
+		if mr.cur >= len(mr.entries2) {
+			err = io.ErrUnexpectedEOF
+			return
+		}
+		entry2 = mr.entries2[mr.cur]
+	}
+	mr.cur++
+	return
+}
+
+// Close does nothing.
+func (mr *MemoryReader) Close() error {
+	return nil
+}
+
+// MemoryWriter is a Writer that keeps written entries in memory.
+// Entries2 stays nil as long as no second read is written.
+type MemoryWriter struct {
+	Entries1 []*fastq.FastqEntry
+	Entries2 []*fastq.FastqEntry
+}
+
+func NewMemoryWriter() *MemoryWriter {
+	return &MemoryWriter{}
+}
+
+// Write appends entry1 to Entries1 and, if not nil, entry2 to Entries2.
+func (mw *MemoryWriter) Write(entry1 *fastq.FastqEntry, entry2 *fastq.FastqEntry) error {
+	mw.Entries1 = append(mw.Entries1, entry1)
+	if entry2 != nil {
+		mw.Entries2 = append(mw.Entries2, entry2)
+	}
+	return nil
+}
+
+// Close does nothing.
+func (mw *MemoryWriter) Close() error {
+	return nil
+}
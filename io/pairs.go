@@ -0,0 +1,137 @@
+package io
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/fredericlemoine/fastqutils/fastq"
+)
+
+// Paired-end synchronisation checks (see FastQParser.SetPairCheck)
+const (
+	PAIRS_UNCHECKED = iota // Paired files are not checked
+	PAIRS_REPORT           // Problems are counted, see FastQParser.PairReport
+	PAIRS_STRICT           // Problems are returned as errors by NextEntry
+)
+
+// Pairing errors, wrapped in a *PairError. They can be tested with
+// errors.Is.
+var (
+	ErrPairNames      = errors.New("read names differ")
+	ErrUnequalLengths = errors.New("paired files do not have the same number of records")
+)
+
+// PairError describes a synchronisation problem between two paired
+// files.
+type PairError struct {
+	File1  string // Name of the first read file
+	File2  string // Name of the second read file
+	Record int    // Record number, starting at 1
+	Err    error  // ErrPairNames or ErrUnequalLengths
+	Detail string // Additional information, may be empty
+}
+
+func (e *PairError) Error() string {
+	msg := fmt.Sprintf("%s / %s: record %d: %v", e.File1, e.File2, e.Record, e.Err)
+	if e.Detail != "" {
+		msg += " (" + e.Detail + ")"
+	}
+	return msg
+}
+
+func (e *PairError) Unwrap() error {
+	return e.Err
+}
+
+// PairReport summarizes the pairing problems found by a FastQParser
+// in PAIRS_REPORT mode.
+type PairReport struct {
+	Mismatches    int   // Number of pairs whose read names differ
+	FirstMismatch error // First of them (a *PairError), nil if none
+	Lengths       error // Non-nil (a *PairError) if the files do not have the same number of records
+}
+
+// SetPairCheck sets how the parser checks that paired files are
+// synchronized: PAIRS_UNCHECKED (default), PAIRS_REPORT or
+// PAIRS_STRICT.
+//
+// When checked, the read names of both reads of each pair must have
+// the same identifier (see fastq.ReadID), and both files must have the
+// same number of records. It has no effect on single-end parsers.
+func (p *FastQParser) SetPairCheck(check int) {
+	p.pairCheck = check
+}
+
+// PairReport returns the pairing problems found so far in PAIRS_REPORT
+// mode.
+func (p *FastQParser) PairReport() PairReport {
+	return p.pairReport
+}
+
+// checkNames checks that entry1 and entry2 come from the same fragment.
+// It returns an error only in PAIRS_STRICT mode.
+func (p *FastQParser) checkNames(entry1, entry2 *fastq.FastqEntry) error {
+	if p.pairCheck == PAIRS_UNCHECKED || bytes.Equal(fastq.ReadID(entry1.Name), fastq.ReadID(entry2.Name)) {
+		return nil
+	}
+	perr := &PairError{
+		File1:  p.file1,
+		File2:  p.file2,
+		Record: p.record + 1,
+		Err:    ErrPairNames,
+		Detail: fmt.Sprintf("%s / %s", entry1.Name, entry2.Name),
+	}
+	if p.pairCheck == PAIRS_STRICT {
+		return perr
+	}
+	p.pairReport.Mismatches++
+	if p.pairReport.FirstMismatch == nil {
+		p.pairReport.FirstMismatch = perr
+	}
+	return nil
+}
+
+// unequalLengths is called when file has no more record while the
+// other paired file still has some. It returns the error NextEntry must
+// return: io.EOF, except in PAIRS_STRICT mode, and in strict mode (see
+// SetStrict) or for interleaved input if pairs are not checked, so that
+// the last record is not silently dropped. In PAIRS_REPORT mode, the
+// problem is reported by PairReport.
+func (p *FastQParser) unequalLengths(file string) error {
+	if p.pairCheck == PAIRS_UNCHECKED && !p.strict && !p.interleaved {
+		return io.EOF
+	}
+	perr := &PairError{
+		File1:  p.file1,
+		File2:  p.file2,
+		Record: p.record + 1,
+		Err:    ErrUnequalLengths,
+		Detail: file + " has no more records",
+	}
+	if p.interleaved {
+		perr.Detail = "odd number of records in interleaved file"
+	}
+	if p.pairCheck == PAIRS_STRICT || p.pairCheck == PAIRS_UNCHECKED {
+		return perr
+	}
+	p.pairReport.Lengths = perr
+	return io.EOF
+}
+
+// endOfFile1 is called when the first read file has no more record,
+// and checks that the second one has no more record either. Blank
+// lines at the end of the second file are skipped, as by readRecord.
+func (p *FastQParser) endOfFile1() error {
+	for {
+		b, err := p.reader2.Peek(1)
+		if err != nil {
+			return io.EOF
+		}
+		if b[0] != '\n' && b[0] != '\r' {
+			return p.unequalLengths(p.file1)
+		}
+		p.reader2.Discard(1)
+	}
+}
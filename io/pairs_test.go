@@ -0,0 +1,121 @@
+package io
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/fredericlemoine/fastqutils/fastq"
+)
+
+func TestReadID(t *testing.T) {
+	cases := map[string]string{
+		"@read1/1":                             "read1",
+		"@read1/2":                             "read1",
+		"@EAS139:136:FC706VJ:2:2104 1:Y:18:AC": "EAS139:136:FC706VJ:2:2104",
+		"@read1\tcomment":                      "read1",
+		">read1/3":                             "read1/3",
+	}
+	for name, want := range cases {
+		if got := string(fastq.ReadID([]byte(name))); got != want {
+			t.Errorf("ReadID(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func pairedParser(in1, in2 string, strict bool, check int) *FastQParser {
+	p := &FastQParser{
+		reader1: bufio.NewReader(strings.NewReader(in1)),
+		reader2: bufio.NewReader(strings.NewReader(in2)),
+		file1:   "r1.fq",
+		file2:   "r2.fq",
+	}
+	p.SetStrict(strict)
+	p.SetPairCheck(check)
+	return p
+}
+
+func TestPairTrailingBlankLines(t *testing.T) {
+	p := pairedParser("@a/1\nAC\n+\nII\n", "@a/2\nAC\n+\nII\n\r\n\n", true, PAIRS_STRICT)
+	n := 0
+	var err error
+	for ; err == nil; n++ {
+		_, _, err = p.NextEntry()
+	}
+	if err != io.EOF || n != 2 {
+		t.Errorf("got error %v after %d records, want EOF after 1 record", err, n-1)
+	}
+}
+
+func TestPairCheck(t *testing.T) {
+	r1 := "@a/1\nAC\n+\nII\n@b/1\nAC\n+\nII\n@c/1\nAC\n+\nII\n"
+	r2 := "@a/2 2:N:0\nAC\n+\nII\n@x/2\nAC\n+\nII\n"
+
+	for _, strict := range []bool{false, true} {
+		p := pairedParser(r1, r2, strict, PAIRS_STRICT)
+		var err error
+		n := 0
+		for ; err == nil; n++ {
+			_, _, err = p.NextEntry()
+		}
+		if !errors.Is(err, ErrPairNames) || n != 2 {
+			t.Errorf("strict=%v: got error %v at record %d, want %v at record 2", strict, err, n, ErrPairNames)
+		}
+
+		p = pairedParser(r1, r2, strict, PAIRS_REPORT)
+		for err = nil; err == nil; {
+			_, _, err = p.NextEntry()
+		}
+		report := p.PairReport()
+		if err != io.EOF || report.Mismatches != 1 || !errors.Is(report.Lengths, ErrUnequalLengths) {
+			t.Errorf("strict=%v: got %v, %+v", strict, err, report)
+		}
+
+		p = pairedParser(r2, r1, strict, PAIRS_STRICT)
+		for err = nil; err == nil || errors.Is(err, ErrPairNames); {
+			_, _, err = p.NextEntry()
+		}
+		if !errors.Is(err, ErrUnequalLengths) {
+			t.Errorf("strict=%v: got error %v, want %v", strict, err, ErrUnequalLengths)
+		}
+
+		// Truncated mate file: only reported in strict mode if pairs are
+		// not checked
+		p = pairedParser(r1, r1[:len(r1)/3], strict, PAIRS_UNCHECKED)
+		for err = nil; err == nil; {
+			_, _, err = p.NextEntry()
+		}
+		if strict && !errors.Is(err, ErrUnequalLengths) || !strict && err != io.EOF {
+			t.Errorf("strict=%v, unchecked pairs: got error %v", strict, err)
+		}
+	}
+
+	p := pairedParser("@a/1\nAC\n+\nII\n", "@a/2\nAC\n+\nIII\n", false, PAIRS_UNCHECKED)
+	if _, _, err := p.NextEntry(); !errors.Is(err, ErrLengthMismatch) {
+		t.Errorf("got error %v, want %v", err, ErrLengthMismatch)
+	}
+}
+
+func TestInterleavedParser(t *testing.T) {
+	// The unpaired last record is an error even if pairs are not checked
+	for _, check := range []int{PAIRS_STRICT, PAIRS_UNCHECKED} {
+		p := &FastQParser{
+			reader1:     bufio.NewReader(strings.NewReader("@a/1\nAC\n+\nII\n@a/2\nGT\n+\nII\n@b/1\nAC\n+\nII\n")),
+			file1:       "il.fq",
+			file2:       "il.fq",
+			interleaved: true,
+		}
+		p.reader2 = p.reader1
+		p.SetPairCheck(check)
+
+		entry1, entry2, err := p.NextEntry()
+		if err != nil || string(entry1.Name) != "@a/1" || string(entry2.Sequence) != "GT" {
+			t.Fatalf("first pair: got %v, %v, %v", entry1, entry2, err)
+		}
+		if _, _, err = p.NextEntry(); !errors.Is(err, ErrUnequalLengths) {
+			t.Errorf("odd number of records: got error %v, want %v", err, ErrUnequalLengths)
+		}
+	}
+}
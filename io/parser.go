@@ -2,11 +2,10 @@ package io
 
 import (
 	"bufio"
-	"compress/gzip"
 	"errors"
+	"fmt"
 	"io"
 	"os"
-	"strings"
 
 	"github.com/fredericlemoine/fastqutils/dsrc"
 	"github.com/fredericlemoine/fastqutils/fastq"
@@ -19,6 +18,17 @@ type FastQParser struct {
 	reader2 *bufio.Reader // paired read file (if any, nil otherwise)
 	closer1 io.Closer     // non-nil when reader1 is backed by a resource that must be released (e.g. a dsrc subprocess)
 	closer2 io.Closer     // same, for reader2
+	file1   string        // name of the first read file, for error reporting
+	file2   string        // name of the paired read file
+	line1   int           // number of lines read so far in the first read file
+	line2   int           // number of lines read so far in the paired read file
+	record  int           // number of records read so far
+	strict  bool          // if true, records are validated (see SetStrict)
+
+	interleaved bool // if true, reader2 is reader1 and pairs are consecutive records
+
+	pairCheck  int        // how paired files are checked (see SetPairCheck)
+	pairReport PairReport // problems found in PAIRS_REPORT mode
 }
 
 func NewSingleEndParser(file string) (fp *FastQParser, err error) {
@@ -31,6 +41,7 @@ func NewSingleEndParser(file string) (fp *FastQParser, err error) {
 	fp = &FastQParser{
 		reader1: reader,
 		closer1: closer,
+		file1:   file,
 	}
 
 	return
@@ -51,10 +62,61 @@ func NewPairedEndParser(read1 string, read2 string) (fp *FastQParser, err error)
 		reader2: reader2,
 		closer1: closer1,
 		closer2: closer2,
+		file1:   read1,
+		file2:   read2,
 	}
 	return
 }
 
+// NewInterleavedParser returns a parser of an interleaved paired-end
+// FASTQ file: first and second reads of each pair are consecutive
+// records of the file. NextEntry returns them as a pair, as if they
+// came from two paired files.
+func NewInterleavedParser(file string) (fp *FastQParser, err error) {
+	var reader *bufio.Reader
+	var closer io.Closer
+	if reader, closer, err = GetReader(file); err != nil {
+		return
+	}
+
+	fp = &FastQParser{
+		reader1:     reader,
+		reader2:     reader,
+		closer1:     closer,
+		file1:       file,
+		file2:       file,
+		interleaved: true,
+	}
+	return
+}
+
+// lines2 returns the line counter of the second reads, which is the
+// one of the first reads for interleaved input.
+func (p *FastQParser) lines2() *int {
+	if p.interleaved {
+		return &p.line1
+	}
+	return &p.line2
+}
+
+// SetStrict enables or disables the validation of records.
+//
+// In strict mode, NextEntry checks that headers start with '@',
+// that separator lines start with '+', that sequence and quality
+// lengths are equal for every read, that quality characters are
+// in the printable range ('!' to '~'), and that the last record is
+// complete. Problems are reported as *ValidationError, carrying the
+// file name, record number and line number of the faulty line.
+// Paired files with different numbers of records are reported as a
+// *PairError (ErrUnequalLengths), even if pairs are not checked (see
+// SetPairCheck).
+//
+// After a validation error, the parser may be used to read the next
+// records, but the faulty record is skipped.
+func (p *FastQParser) SetStrict(strict bool) {
+	p.strict = strict
+}
+
 // Close releases any resources backing the parser's underlying readers
 // (for example, waits for and releases a dsrc decompression
 // subprocess). It is a no-op for plain-text input and a simple
@@ -94,12 +156,16 @@ func (m *multiReadCloser) Close() error {
 	return err
 }
 
-// GetReader opens file for reading FASTQ data, auto-detecting gzip
-// (.gz) and DSRC (.dsrc) compression from the file extension. It
-// returns a buffered reader plus a Closer for any backing resource (an
-// open file, a gzip stream, or — for .dsrc — a decompression
-// subprocess) that should be released once reading is finished; closer
-// may be nil when there is nothing to release (e.g. reading from
+// GetReader opens file for reading FASTQ data, auto-detecting its
+// compression from its content (see DetectCompression), so that
+// compressed data is also recognized on stdin or in files without the
+// usual extension. DSRC archives are detected from their .dsrc
+// extension, or from their header (see DetectCompression), and are
+// only supported in files: dsrc needs to seek to their footer. It returns a
+// buffered reader plus a Closer for any backing resource (an open file,
+// a decompression stream, or — for .dsrc — a decompression subprocess)
+// that should be released once reading is finished; closer may be nil
+// when there is nothing to release (e.g. reading plain data from
 // stdin).
 func GetReader(file string) (reader *bufio.Reader, closer io.Closer, err error) {
 	if dsrc.IsDsrcFile(file) {
@@ -107,7 +173,8 @@ func GetReader(file string) (reader *bufio.Reader, closer io.Closer, err error)
 	}
 
 	var fi *os.File
-	var gr *gzip.Reader
+	var dr io.Reader
+	var dc io.Closer
 
 	if file == "stdin" || file == "-" {
 		fi = os.Stdin
@@ -117,22 +184,36 @@ func GetReader(file string) (reader *bufio.Reader, closer io.Closer, err error)
 		}
 	}
 
-	if strings.HasSuffix(file, ".gz") {
-		if gr, err = gzip.NewReader(fi); err != nil {
-			return
+	raw := bufio.NewReader(fi)
+	if fi != os.Stdin {
+		var isDsrc bool
+		if isDsrc, err = dsrcFile(fi, raw); isDsrc || err != nil {
+			fi.Close()
+			if err != nil {
+				return
+			}
+			return dsrc.GetReader(file)
 		}
-		reader = bufio.NewReader(gr)
+	}
+	if dr, dc, err = decompress(raw); err != nil {
+		if fi != os.Stdin {
+			fi.Close()
+		}
+		return
+	}
+	if dr == raw {
+		reader = raw
 	} else {
-		reader = bufio.NewReader(fi)
+		reader = bufio.NewReader(dr)
 	}
 
-	// Close the gzip reader before the underlying file, since it may
+	// Close the decompressor before the underlying file, since it may
 	// need to read trailing bytes from it to verify the checksum.
 	var closers []io.Closer
-	if gr != nil {
-		closers = append(closers, gr)
+	if dc != nil {
+		closers = append(closers, dc)
 	}
-	if fi != nil && fi != os.Stdin {
+	if fi != os.Stdin {
 		closers = append(closers, fi)
 	}
 	if len(closers) > 0 {
@@ -141,24 +222,135 @@ func GetReader(file string) (reader *bufio.Reader, closer io.Closer, err error)
 	return
 }
 
-// Readln returns a single line (without the ending \n)
-// from the input buffered reader.
+// dsrcFile returns true if the file f, whose buffered reader is r, is
+// a DSRC archive without the .dsrc extension, and an error if it looks
+// like a truncated DSRC archive.
+func dsrcFile(f *os.File, r *bufio.Reader) (bool, error) {
+	// Peek returns an error if the file is shorter than a DSRC header,
+	// which is not a problem here
+	header, _ := r.Peek(dsrcHeaderLength)
+	if !isDsrcHeader(header, -1) {
+		return false, nil
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	if !isDsrcHeader(header, info.Size()) {
+		return false, fmt.Errorf("%s looks like a dsrc archive, but its size does not match its header: it may be truncated", f.Name())
+	}
+	return true, nil
+}
+
+// readLine returns a single line (without the ending \n or \r\n)
+// from the input buffered reader. A last line without ending \n
+// is returned without error; io.EOF is returned iff there is no
+// more data to read.
+func readLine(r *bufio.Reader) (line []byte, err error) {
+	return appendLine(r, nil)
+}
+
+// appendLine is readLine, but appends the line to dst instead of
+// allocating a new slice, so that the buffer of dst can be reused.
+func appendLine(r *bufio.Reader, dst []byte) (line []byte, err error) {
+	var chunk []byte
+	start := len(dst)
+	line = dst
+	for {
+		chunk, err = r.ReadSlice('\n')
+		line = append(line, chunk...)
+		if err != bufio.ErrBufferFull {
+			break
+		}
+	}
+	if err != nil {
+		if err != io.EOF || len(line) == start {
+			return
+		}
+		err = nil
+	}
+	if n := len(line); n > start && line[n-1] == '\n' {
+		line = line[:n-1]
+	}
+	if n := len(line); n > start && line[n-1] == '\r' {
+		line = line[:n-1]
+	}
+	return
+}
+
+// Readln returns the name, sequence and quality of the next
+// FASTQ record of the input buffered reader.
+// See readRecord for the supported layouts.
 // An error is returned iff there is an error with the
-// buffered reader.
+// buffered reader, or if the record is incomplete.
 func Readln(r *bufio.Reader) (name, seq, qual []byte, err error) {
-	if name, err = r.ReadBytes('\n'); err == nil && name[len(name)-1] == '\n' {
-		name = name[:len(name)-1]
-		if seq, err = r.ReadBytes('\n'); err == nil && seq[len(seq)-1] == '\n' {
-			seq = seq[:len(seq)-1]
-			r.ReadBytes('\n') // skip one line
-			if qual, err = r.ReadBytes('\n'); err == nil && qual[len(qual)-1] == '\n' {
-				qual = qual[:len(qual)-1]
+	var line int
+	return readRecord(r, &line)
+}
+
+// readRecord reads the next FASTQ record of r, and adds the number
+// of lines read to line.
+//
+// Both the standard 4-line layout and the legacy multi-line layout
+// (sequence and quality wrapped over several lines) are supported:
+// the sequence extends up to the '+' separator line, and the quality
+// extends over as many lines as needed to reach the sequence length.
+// Windows line endings (\r\n) are accepted, and blank lines before a
+// record (e.g. at the end of the file) are skipped.
+//
+// io.EOF is returned iff there is no more record to read,
+// io.ErrUnexpectedEOF if the last record is incomplete.
+func readRecord(r *bufio.Reader, line *int) (name, seq, qual []byte, err error) {
+	var entry fastq.FastqEntry
+	err = readRecordInto(r, line, &entry)
+	return entry.Name, entry.Sequence, entry.Quality, err
+}
+
+// readRecordInto is readRecord, but stores the record in entry,
+// reusing the buffers of its fields.
+func readRecordInto(r *bufio.Reader, line *int, entry *fastq.FastqEntry) (err error) {
+	entry.Name = entry.Name[:0]
+	for len(entry.Name) == 0 {
+		if entry.Name, err = appendLine(r, entry.Name[:0]); err != nil {
+			return
+		}
+		*line++
+	}
+	// Sequence, up to the separator
+	entry.Sequence = entry.Sequence[:0]
+	for {
+		start := len(entry.Sequence)
+		if entry.Sequence, err = appendLine(r, entry.Sequence); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return
+		}
+		*line++
+		if len(entry.Sequence) > start && entry.Sequence[start] == '+' {
+			entry.Sequence = entry.Sequence[:start]
+			break
+		}
+	}
+	// Quality, at least one line, up to the sequence length
+	entry.Quality = entry.Quality[:0]
+	for first := true; first || len(entry.Quality) < len(entry.Sequence); first = false {
+		if entry.Quality, err = appendLine(r, entry.Quality); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
 			}
+			return
 		}
+		*line++
 	}
 	return
 }
 
+// Next implements Reader, it is equivalent to NextEntry.
+func (p *FastQParser) Next() (entry1 *fastq.FastqEntry, entry2 *fastq.FastqEntry, err error) {
+	return p.NextEntry()
+}
+
 // Returns the next entries:
 // If paired end returns 2 fastq entries
 // If single end: returns 1 entry and nil
@@ -166,7 +358,14 @@ func (p *FastQParser) NextEntry() (entry1 *fastq.FastqEntry, entry2 *fastq.Fastq
 	var name1, name2 []byte
 	var seq1, seq2 []byte
 	var qual1, qual2 []byte
-	if name1, seq1, qual1, err = Readln(p.reader1); err != nil {
+	if p.strict {
+		return p.nextValidEntry()
+	}
+
+	if name1, seq1, qual1, err = readRecord(p.reader1, &p.line1); err != nil {
+		if err == io.EOF && p.reader2 != nil {
+			err = p.endOfFile1()
+		}
 		return
 	}
 	entry1 = &fastq.FastqEntry{
@@ -176,12 +375,15 @@ func (p *FastQParser) NextEntry() (entry1 *fastq.FastqEntry, entry2 *fastq.Fastq
 	}
 
 	if p.reader2 != nil {
-		if name2, seq2, qual2, err = Readln(p.reader2); err != nil {
+		if name2, seq2, qual2, err = readRecord(p.reader2, p.lines2()); err != nil {
+			if err == io.EOF {
+				err = p.unequalLengths(p.file2)
+			}
 			return
 		}
 
 		if len(seq2) != len(qual2) {
-			err = errors.New("length of sequence is different from length of quality")
+			err = p.lengthMismatch(seq2, qual2)
 			return
 		}
 		entry2 = &fastq.FastqEntry{
@@ -189,6 +391,78 @@ func (p *FastQParser) NextEntry() (entry1 *fastq.FastqEntry, entry2 *fastq.Fastq
 			Sequence: seq2,
 			Quality:  qual2,
 		}
+		if err = p.checkNames(entry1, entry2); err != nil {
+			return
+		}
 	}
+	p.record++
 	return
 }
+
+// lengthMismatch returns the error of a second read whose sequence
+// and quality lengths differ.
+func (p *FastQParser) lengthMismatch(seq, qual []byte) error {
+	return &ValidationError{
+		File:   p.file2,
+		Record: p.record + 1,
+		Line:   *p.lines2(),
+		Err:    ErrLengthMismatch,
+		Detail: fmt.Sprintf("sequence: %d, quality: %d", len(seq), len(qual)),
+	}
+}
+
+// NextInto is NextEntry, but stores the records in the caller-owned
+// entry1 and entry2, reusing the buffers of their fields instead of
+// allocating new ones. It returns entry1 and entry2, or entry1 and nil
+// for single-end input (entry2 may then be nil). The content of the
+// entries is only valid until the next call.
+//
+// On error, the content of the entries is undefined.
+func (p *FastQParser) NextInto(entry1, entry2 *fastq.FastqEntry) (*fastq.FastqEntry, *fastq.FastqEntry, error) {
+	if p.strict {
+		// Validation is not on the fast path, entries are copied
+		e1, e2, err := p.nextValidEntry()
+		if err != nil {
+			return nil, nil, err
+		}
+		copyEntry(entry1, e1)
+		if e2 == nil {
+			return entry1, nil, nil
+		}
+		copyEntry(entry2, e2)
+		return entry1, entry2, nil
+	}
+
+	if err := readRecordInto(p.reader1, &p.line1, entry1); err != nil {
+		if err == io.EOF && p.reader2 != nil {
+			err = p.endOfFile1()
+		}
+		return nil, nil, err
+	}
+	if p.reader2 == nil {
+		p.record++
+		return entry1, nil, nil
+	}
+
+	if err := readRecordInto(p.reader2, p.lines2(), entry2); err != nil {
+		if err == io.EOF {
+			err = p.unequalLengths(p.file2)
+		}
+		return nil, nil, err
+	}
+	if len(entry2.Sequence) != len(entry2.Quality) {
+		return nil, nil, p.lengthMismatch(entry2.Sequence, entry2.Quality)
+	}
+	if err := p.checkNames(entry1, entry2); err != nil {
+		return nil, nil, err
+	}
+	p.record++
+	return entry1, entry2, nil
+}
+
+// copyEntry copies the fields of src into dst, reusing its buffers.
+func copyEntry(dst, src *fastq.FastqEntry) {
+	dst.Name = append(dst.Name[:0], src.Name...)
+	dst.Sequence = append(dst.Sequence[:0], src.Sequence...)
+	dst.Quality = append(dst.Quality[:0], src.Quality...)
+}
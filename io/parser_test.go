@@ -0,0 +1,154 @@
+package io
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fredericlemoine/fastqutils/fastq"
+)
+
+// writeFastq writes n generated records of the given length in a
+// temporary file, and returns its name.
+func writeFastq(tb testing.TB, n, length int) string {
+	tb.Helper()
+	file := filepath.Join(tb.TempDir(), "reads.fq")
+	w, closer, err := GetWriter(file, PLAIN)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		WriteEntry(w, fastq.GenFastQEntry(length, i, 35, 74))
+	}
+	if err = closer.Close(); err != nil {
+		tb.Fatal(err)
+	}
+	return file
+}
+
+func TestNextInto(t *testing.T) {
+	// Records longer than the bufio buffer are read in several chunks
+	for _, length := range []int{10, 5000} {
+		file := writeFastq(t, 20, length)
+		p1, err := NewPairedEndParser(file, file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p2, err := NewPairedEndParser(file, file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := ReuseEntries(p2)
+		var prev *fastq.FastqEntry
+		for i := 0; ; i++ {
+			want1, want2, err1 := p1.NextEntry()
+			got1, got2, err2 := r.Next()
+			if err1 != err2 {
+				t.Fatalf("record %d: got error %v, want %v", i, err2, err1)
+			}
+			if err1 != nil {
+				break
+			}
+			if prev != nil && got1 != prev {
+				t.Errorf("record %d: entry has not been reused", i)
+			}
+			prev = got1
+			checkEntries(t, []*fastq.FastqEntry{got1, got2}, []*fastq.FastqEntry{want1, want2}, true)
+		}
+		p1.Close()
+		p2.Close()
+	}
+}
+
+func TestBlankLines(t *testing.T) {
+	input := "@r1\r\nAC\r\n+\r\nII\r\n\r\n@r2\nAC\n+\nII\n\n\n"
+	p := &FastQParser{reader1: bufio.NewReader(strings.NewReader(input))}
+	entry := &fastq.FastqEntry{}
+	var names []string
+	for {
+		e, _, err := p.NextInto(entry, nil)
+		if err != nil {
+			if err != io.EOF {
+				t.Errorf("got error %v, want EOF", err)
+			}
+			break
+		}
+		names = append(names, string(e.Name))
+	}
+	if fmt.Sprint(names) != "[@r1 @r2]" {
+		t.Errorf("got records %v, want [@r1 @r2]", names)
+	}
+}
+
+func TestWriteEntry(t *testing.T) {
+	var b bytes.Buffer
+	w := bufio.NewWriter(&b)
+	entry := &fastq.FastqEntry{Name: []byte("@read1"), Sequence: []byte("ACGT"), Quality: []byte("IIII")}
+	WriteEntry(w, entry)
+	WriteEntryFasta(w, entry)
+	w.Flush()
+	if want := "@read1\nACGT\n+\nIIII\n>read1\nACGT\n"; b.String() != want {
+		t.Errorf("got %q, want %q", b.String(), want)
+	}
+}
+
+// benchmarkRead reads a file of 10000 records, reusing entries or not.
+func benchmarkRead(b *testing.B, reuse bool) {
+	file := writeFastq(b, 10000, 150)
+	info, err := os.Stat(file)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(info.Size())
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p, err := NewSingleEndParser(file)
+		if err != nil {
+			b.Fatal(err)
+		}
+		var r Reader = p
+		if reuse {
+			r = ReuseEntries(p)
+		}
+		if err = ForEach(r, func(entry1, entry2 *fastq.FastqEntry) error { return nil }); err != nil {
+			b.Fatal(err)
+		}
+		p.Close()
+	}
+}
+
+func BenchmarkNextEntry(b *testing.B) { benchmarkRead(b, false) }
+func BenchmarkNextInto(b *testing.B)  { benchmarkRead(b, true) }
+
+func BenchmarkWriteEntry(b *testing.B) {
+	entry := fastq.GenFastQEntry(150, 1, 35, 74)
+	w := bufio.NewWriter(discard{})
+	b.SetBytes(int64(len(entry.Name) + 2*len(entry.Sequence) + 5))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		WriteEntry(w, entry)
+	}
+	w.Flush()
+}
+
+// BenchmarkWriteEntrySprintf is the previous implementation of
+// WriteEntry, for comparison.
+func BenchmarkWriteEntrySprintf(b *testing.B) {
+	entry := fastq.GenFastQEntry(150, 1, 35, 74)
+	w := bufio.NewWriter(discard{})
+	b.SetBytes(int64(len(entry.Name) + 2*len(entry.Sequence) + 5))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		w.WriteString(fmt.Sprintf("%s\n%s\n+\n%s\n", entry.Name, entry.Sequence, entry.Quality))
+	}
+	w.Flush()
+}
+
+type discard struct{}
+
+func (discard) Write(p []byte) (int, error) { return len(p), nil }
@@ -0,0 +1,210 @@
+package io
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/fredericlemoine/fastqutils/fastq"
+)
+
+// Supported input/output record formats
+const (
+	FASTQ = iota
+	FASTA
+	BAM
+)
+
+// Reader is implemented by every source of sequencing records
+// (FASTQ, FASTA, unaligned BAM, in-memory slices...).
+//
+// Next returns the next record, or the next pair of records if the
+// input is paired-end. For single-end input, entry2 is nil. At the end
+// of the input, Next returns io.EOF.
+//
+// Close releases any resource backing the Reader, and must be called
+// once the caller is done reading.
+type Reader interface {
+	Next() (entry1 *fastq.FastqEntry, entry2 *fastq.FastqEntry, err error)
+	Close() error
+}
+
+// Writer is implemented by every sink of sequencing records.
+//
+// Write writes entry1, and entry2 if it is not nil, to the
+// underlying output(s). Depending on the implementation, entry2 may
+// go to a second file, to the same file, or be ignored if the
+// writer has not been given any output for second reads.
+//
+// Close flushes and releases the underlying output(s), and must be
+// called exactly once when the caller is done writing.
+type Writer interface {
+	Write(entry1 *fastq.FastqEntry, entry2 *fastq.FastqEntry) error
+	Close() error
+}
+
+// FormatFromString returns the format code corresponding to
+// the given format name (fastq, fasta or bam).
+func FormatFromString(format string) (f int, err error) {
+	switch format {
+	case "fastq":
+		f = FASTQ
+	case "fasta":
+		f = FASTA
+	case "bam":
+		f = BAM
+	default:
+		err = fmt.Errorf("this format does not exist : %s, possible values are : fastq, fasta, bam", format)
+	}
+	return
+}
+
+// NewReader opens a Reader on the given file(s), in the given format.
+//
+// If input2 is "none", the input is considered single-end. BAM input
+// stores both reads of a pair in the same file, so input2 must be "none"
+// for this format.
+func NewReader(format int, input1, input2 string) (r Reader, err error) {
+	switch format {
+	case FASTQ:
+		if input2 != "none" {
+			r, err = NewPairedEndParser(input1, input2)
+		} else {
+			r, err = NewSingleEndParser(input1)
+		}
+	case FASTA:
+		if input2 != "none" {
+			r, err = NewPairedEndFastaParser(input1, input2)
+		} else {
+			r, err = NewSingleEndFastaParser(input1)
+		}
+	case BAM:
+		if input2 != "none" {
+			err = fmt.Errorf("bam input contains both reads of a pair, a second input file cannot be given")
+			return
+		}
+		r, err = NewBamParser(input1)
+	default:
+		err = fmt.Errorf("unknown input format code : %d", format)
+	}
+	if err != nil {
+		r = nil
+	}
+	return
+}
+
+// NewInterleavedReader opens a Reader on an interleaved paired-end
+// file, in the given format: consecutive records are returned as
+// pairs. This is not supported for BAM files, whose reads are
+// paired using their flags (see BamParser).
+func NewInterleavedReader(format int, input string) (r Reader, err error) {
+	switch format {
+	case FASTQ:
+		r, err = NewInterleavedParser(input)
+	case FASTA:
+		r, err = NewInterleavedFastaParser(input)
+	case BAM:
+		err = fmt.Errorf("interleaved input is not supported for bam files, reads are paired using their flags")
+	default:
+		err = fmt.Errorf("unknown input format code : %d", format)
+	}
+	if err != nil {
+		r = nil
+	}
+	return
+}
+
+// ForEach calls f on every record (or pair of records) returned by r,
+// until the end of the input. It stops at the first error returned by
+// r or by f. Reaching the end of the input is not an error.
+func ForEach(r Reader, f func(entry1, entry2 *fastq.FastqEntry) error) (err error) {
+	var entry1, entry2 *fastq.FastqEntry
+	for {
+		if entry1, entry2, err = r.Next(); err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return
+		}
+		if err = f(entry1, entry2); err != nil {
+			return
+		}
+	}
+}
+
+// EntryFiller is implemented by Readers able to read records into
+// caller-owned entries, reusing their buffers (see
+// FastQParser.NextInto).
+type EntryFiller interface {
+	Reader
+	NextInto(entry1, entry2 *fastq.FastqEntry) (*fastq.FastqEntry, *fastq.FastqEntry, error)
+}
+
+// reusingReader returns the same two entries at every call to Next.
+type reusingReader struct {
+	EntryFiller
+	entry1, entry2 fastq.FastqEntry
+}
+
+func (r *reusingReader) Next() (entry1 *fastq.FastqEntry, entry2 *fastq.FastqEntry, err error) {
+	return r.NextInto(&r.entry1, &r.entry2)
+}
+
+// ReuseEntries returns a Reader over the records of r that does not
+// allocate new entries for every record if r is an EntryFiller: the
+// entries returned by Next are then only valid until the next call, and
+// must be copied if they are kept. Otherwise, r itself is returned.
+//
+// This is intended for streaming commands that do not keep records,
+// such as format conversions or statistics.
+func ReuseEntries(r Reader) Reader {
+	if f, ok := r.(EntryFiller); ok {
+		return &reusingReader{EntryFiller: f}
+	}
+	return r
+}
+
+// PeekReader is a Reader whose first records are read in advance, so
+// that they can be examined (e.g. to detect the quality encoding)
+// before being returned by Next, followed by the other records.
+type PeekReader struct {
+	Reader
+	entries1, entries2 []*fastq.FastqEntry
+	err                error // Error returned while reading in advance
+	cur                int
+}
+
+// NewPeekReader reads up to n records (or pairs of records) of r in
+// advance. An error while reading them is returned by Next, after the
+// records read before it.
+func NewPeekReader(r Reader, n int) *PeekReader {
+	pr := &PeekReader{Reader: r}
+	for len(pr.entries1) < n {
+		entry1, entry2, err := r.Next()
+		if err != nil {
+			pr.err = err
+			break
+		}
+		pr.entries1 = append(pr.entries1, entry1)
+		pr.entries2 = append(pr.entries2, entry2)
+	}
+	return pr
+}
+
+// Peeked returns the records read in advance. entries2 contains nil
+// entries for single-end input.
+func (pr *PeekReader) Peeked() (entries1, entries2 []*fastq.FastqEntry) {
+	return pr.entries1, pr.entries2
+}
+
+// Next returns the records read in advance, then the next records of
+// the underlying Reader.
+func (pr *PeekReader) Next() (entry1 *fastq.FastqEntry, entry2 *fastq.FastqEntry, err error) {
+	if pr.cur < len(pr.entries1) {
+		pr.cur++
+		return pr.entries1[pr.cur-1], pr.entries2[pr.cur-1], nil
+	}
+	if pr.err != nil {
+		return nil, nil, pr.err
+	}
+	return pr.Reader.Next()
+}
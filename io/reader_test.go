@@ -0,0 +1,167 @@
+package io
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fredericlemoine/fastqutils/fastq"
+)
+
+func testEntries() (entries1, entries2 []*fastq.FastqEntry) {
+	entries1 = []*fastq.FastqEntry{
+		{Name: []byte("@read1/1"), Sequence: []byte("ACGTACGTAC"), Quality: []byte("IIIIIIIIII")},
+		{Name: []byte("@read2/1"), Sequence: []byte("TTTTGGGGCC"), Quality: []byte("!!!!!!!!!!")},
+	}
+	entries2 = []*fastq.FastqEntry{
+		{Name: []byte("@read1/2"), Sequence: []byte("GGGGAAAACC"), Quality: []byte("ABCDEFGHIJ")},
+		{Name: []byte("@read2/2"), Sequence: []byte("CCCCAAAATT"), Quality: []byte("##########")},
+	}
+	return
+}
+
+func checkEntries(t *testing.T, got, want []*fastq.FastqEntry, withQual bool) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if string(got[i].Name) != string(want[i].Name) ||
+			string(got[i].Sequence) != string(want[i].Sequence) ||
+			(withQual && string(got[i].Quality) != string(want[i].Quality)) {
+			t.Errorf("entry %d: got %s/%s/%s, want %s/%s/%s", i,
+				got[i].Name, got[i].Sequence, got[i].Quality,
+				want[i].Name, want[i].Sequence, want[i].Quality)
+		}
+	}
+}
+
+// roundTrip writes entries with w, reads them back with the Reader
+// returned by open, and checks that they did not change.
+func roundTrip(t *testing.T, w Writer, open func() (Reader, error), withQual bool) {
+	t.Helper()
+	entries1, entries2 := testEntries()
+	if err := ForEach(NewMemoryReader(entries1, entries2), w.Write); err != nil {
+		t.Fatalf("writing: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("closing writer: %v", err)
+	}
+
+	r, err := open()
+	if err != nil {
+		t.Fatalf("opening reader: %v", err)
+	}
+	defer r.Close()
+	mw := NewMemoryWriter()
+	if err = ForEach(r, mw.Write); err != nil {
+		t.Fatalf("reading: %v", err)
+	}
+	checkEntries(t, mw.Entries1, entries1, withQual)
+	checkEntries(t, mw.Entries2, entries2, withQual)
+}
+
+func TestFastqRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	out1, out2 := filepath.Join(dir, "r1.fq"), filepath.Join(dir, "r2.fq")
+	w, err := NewFastqWriter(out1, out2, GZIP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roundTrip(t, w, func() (Reader, error) { return NewReader(FASTQ, out1+".gz", out2+".gz") }, true)
+}
+
+func TestFastaRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	out1, out2 := filepath.Join(dir, "r1.fa"), filepath.Join(dir, "r2.fa")
+	w, err := NewFastaWriter(out1, out2, PLAIN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roundTrip(t, w, func() (Reader, error) { return NewReader(FASTA, out1, out2) }, false)
+
+	b, err := os.ReadFile(out1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := ">read1/1\nACGTACGTAC\n>read2/1\nTTTTGGGGCC\n"; string(b) != want {
+		t.Errorf("fasta output: got %q, want %q", b, want)
+	}
+}
+
+func TestBamRoundTrip(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "reads.bam")
+	w, err := NewBamWriter(out, 33)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roundTrip(t, w, func() (Reader, error) { return NewReader(BAM, out, "none") }, true)
+}
+
+func TestMemoryReaderSingleEnd(t *testing.T) {
+	entries1, _ := testEntries()
+	r := NewMemoryReader(entries1, nil)
+	n := 0
+	err := ForEach(r, func(entry1, entry2 *fastq.FastqEntry) error {
+		if entry2 != nil {
+			t.Errorf("unexpected second read for single end input")
+		}
+		n++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(entries1) {
+		t.Errorf("got %d records, want %d", n, len(entries1))
+	}
+}
+
+func TestMultiLineCRLF(t *testing.T) {
+	dir := t.TempDir()
+	fq := filepath.Join(dir, "reads.fq")
+	fa := filepath.Join(dir, "reads.fa")
+	if err := os.WriteFile(fq, []byte("@read1\r\nACG\r\nTAC\r\n+\r\nIII\r\n@II\r\n@read2\r\nTT\r\n+read2\r\n!!"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(fa, []byte(">read1\r\nACG\r\nTAC\r\n\r\n>read2\nTT"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	want := []*fastq.FastqEntry{
+		{Name: []byte("@read1"), Sequence: []byte("ACGTAC"), Quality: []byte("III@II")},
+		{Name: []byte("@read2"), Sequence: []byte("TT"), Quality: []byte("!!")},
+	}
+
+	for _, c := range []struct {
+		format   int
+		file     string
+		withQual bool
+	}{{FASTQ, fq, true}, {FASTA, fa, false}} {
+		r, err := NewReader(c.format, c.file, "none")
+		if err != nil {
+			t.Fatal(err)
+		}
+		mw := NewMemoryWriter()
+		if err = ForEach(r, mw.Write); err != nil {
+			t.Fatalf("%s: %v", c.file, err)
+		}
+		r.Close()
+		checkEntries(t, mw.Entries1, want, c.withQual)
+	}
+}
+
+func TestPeekReader(t *testing.T) {
+	entries1, entries2 := testEntries()
+	for n := 0; n <= 3; n++ {
+		pr := NewPeekReader(NewMemoryReader(entries1, entries2), n)
+		peeked1, peeked2 := pr.Peeked()
+		checkEntries(t, peeked1, entries1[:min(n, len(entries1))], true)
+		checkEntries(t, peeked2, entries2[:min(n, len(entries2))], true)
+		w := NewMemoryWriter()
+		if err := ForEach(pr, w.Write); err != nil {
+			t.Fatal(err)
+		}
+		checkEntries(t, w.Entries1, entries1, true)
+		checkEntries(t, w.Entries2, entries2, true)
+	}
+}
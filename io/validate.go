@@ -0,0 +1,173 @@
+package io
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/fredericlemoine/fastqutils/fastq"
+)
+
+// Validation errors, wrapped in a *ValidationError by the strict
+// FastQParser (see FastQParser.SetStrict). They can be tested with
+// errors.Is.
+var (
+	ErrMalformedHeader  = errors.New("header does not start with '@'")
+	ErrMissingSeparator = errors.New("separator line does not start with '+'")
+	ErrLengthMismatch   = errors.New("length of sequence is different from length of quality")
+	ErrIllegalQuality   = errors.New("illegal quality character")
+	ErrTruncatedRecord  = errors.New("truncated record")
+)
+
+// ValidationError describes a malformed FASTQ record.
+type ValidationError struct {
+	File   string // Name of the file containing the record
+	Record int    // Record number, starting at 1
+	Line   int    // Line number of the faulty line, starting at 1
+	Err    error  // One of the Err* validation errors
+	Detail string // Additional information, may be empty
+}
+
+func (e *ValidationError) Error() string {
+	msg := fmt.Sprintf("%s: record %d, line %d: %v", e.File, e.Record, e.Line, e.Err)
+	if e.Detail != "" {
+		msg += " (" + e.Detail + ")"
+	}
+	return msg
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// isSequenceLine returns true if line only contains letters or '.'
+func isSequenceLine(line []byte) bool {
+	for _, c := range line {
+		if !(c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// readValid reads and validates the next FASTQ record of r, using
+// the same layout rules as readRecord (multi-line records, \r\n line
+// endings and blank lines before a record are accepted). line is the number of lines read so far
+// in r, and is updated with the number of lines read; record is the
+// number of the record being read, used for error reporting.
+//
+// When a record is invalid, it is still read up to its end as far as
+// its structure allows, so that the next call starts at the next
+// record, and the first problem found is returned.
+// io.EOF is returned iff there is no more data to read.
+func readValid(r *bufio.Reader, file string, line *int, record int) (entry *fastq.FastqEntry, err error) {
+	var l, name, seq, qual []byte
+	var verr error // First problem found in the record
+
+	invalid := func(lineno int, e error, detail string) {
+		if verr == nil {
+			verr = &ValidationError{
+				File:   file,
+				Record: record,
+				Line:   lineno,
+				Err:    e,
+				Detail: detail,
+			}
+		}
+	}
+
+	for len(name) == 0 {
+		if name, err = readLine(r); err != nil {
+			return
+		}
+		*line++
+	}
+	if name[0] != '@' {
+		invalid(*line, ErrMalformedHeader, "")
+	}
+
+	// Sequence, up to the separator
+	for {
+		if l, err = readLine(r); err != nil {
+			if err == io.EOF {
+				invalid(*line+1, ErrTruncatedRecord, "missing separator line")
+				return nil, verr
+			}
+			return
+		}
+		*line++
+		if len(l) > 0 && l[0] == '+' {
+			break
+		}
+		if !isSequenceLine(l) {
+			// We consider this line as the separator, to go on
+			invalid(*line, ErrMissingSeparator, "")
+			break
+		}
+		seq = append(seq, l...)
+	}
+
+	// Quality, at least one line, up to the sequence length
+	for first := true; first || len(qual) < len(seq); first = false {
+		if l, err = readLine(r); err != nil {
+			if err == io.EOF {
+				invalid(*line+1, ErrTruncatedRecord, fmt.Sprintf("expected %d quality values, found %d", len(seq), len(qual)))
+				return nil, verr
+			}
+			return
+		}
+		*line++
+		for i, q := range l {
+			if q < '!' || q > '~' {
+				invalid(*line, ErrIllegalQuality, fmt.Sprintf("character %q at position %d", q, i+1))
+				break
+			}
+		}
+		qual = append(qual, l...)
+	}
+	if len(seq) != len(qual) {
+		invalid(*line, ErrLengthMismatch, fmt.Sprintf("sequence: %d, quality: %d", len(seq), len(qual)))
+	}
+
+	if verr != nil {
+		return nil, verr
+	}
+	entry = &fastq.FastqEntry{
+		Name:     name,
+		Sequence: seq,
+		Quality:  qual,
+	}
+	return
+}
+
+// nextValidEntry is NextEntry in strict mode.
+func (p *FastQParser) nextValidEntry() (entry1 *fastq.FastqEntry, entry2 *fastq.FastqEntry, err error) {
+	var err2 error
+
+	record := p.record + 1
+	entry1, err = readValid(p.reader1, p.file1, &p.line1, record)
+	if p.reader2 != nil {
+		entry2, err2 = readValid(p.reader2, p.file2, p.lines2(), record)
+	}
+	if err == io.EOF && (p.reader2 == nil || err2 == io.EOF) {
+		return nil, nil, io.EOF
+	}
+	if p.reader2 != nil && (err == io.EOF || err2 == io.EOF) {
+		if err == io.EOF {
+			return nil, nil, p.unequalLengths(p.file1)
+		}
+		return nil, nil, p.unequalLengths(p.file2)
+	}
+	if err == nil {
+		err = err2
+	}
+	if err == nil && entry2 != nil {
+		err = p.checkNames(entry1, entry2)
+	}
+	p.record = record
+	if err != nil {
+		entry1, entry2 = nil, nil
+	}
+	return
+}
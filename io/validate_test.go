@@ -0,0 +1,58 @@
+package io
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestStrictParser(t *testing.T) {
+	cases := []struct {
+		name   string
+		input  string
+		err    error
+		record int
+		line   int
+	}{
+		{"valid", "@r1\nACGT\n+\nIIII\n@r2\nAC\n+r2\nII", nil, 0, 0},
+		{"header", "@r1\nACGT\n+\nIIII\nr2\nAC\n+\nII\n", ErrMalformedHeader, 2, 5},
+		{"separator", "@r1\nACGT\n-\nIIII\n", ErrMissingSeparator, 1, 3},
+		{"multiline", "@r1\r\nAC\r\nGT\r\n+\r\nII\r\nII\r\n@r2\nAC\n+\n@I", nil, 0, 0},
+		{"length", "@r1\nACGT\n+\nIIIII\n", ErrLengthMismatch, 1, 4},
+		{"short quality", "@r1\nACGT\n+\nIII\n@r2\nAC\n+\nII\n", ErrLengthMismatch, 1, 5},
+		{"quality", "@r1\nACGT\n+\nII I\n", ErrIllegalQuality, 1, 4},
+		{"truncated", "@r1\nACGT\n+\nIIII\n@r2\nAC\n", ErrTruncatedRecord, 2, 7},
+		{"truncated quality", "@r1\nACGT\n+\nII", ErrTruncatedRecord, 1, 5},
+		{"blank lines", "@r1\r\nAC\r\n+\r\nII\r\n\r\n@r2\nAC\n+\nII\n\n", nil, 0, 0},
+		{"blank line header", "@r1\nAC\n+\nII\n\nr2\nAC\n+\nII\n", ErrMalformedHeader, 2, 6},
+	}
+
+	for _, c := range cases {
+		p := &FastQParser{
+			reader1: bufio.NewReader(strings.NewReader(c.input)),
+			file1:   "test.fq",
+		}
+		p.SetStrict(true)
+
+		var err error
+		for err == nil {
+			_, _, err = p.NextEntry()
+		}
+		if c.err == nil {
+			if err != io.EOF {
+				t.Errorf("%s: unexpected error %v", c.name, err)
+			}
+			continue
+		}
+		var verr *ValidationError
+		if !errors.As(err, &verr) || !errors.Is(err, c.err) {
+			t.Errorf("%s: got error %v, want %v", c.name, err, c.err)
+			continue
+		}
+		if verr.Record != c.record || verr.Line != c.line || verr.File != "test.fq" {
+			t.Errorf("%s: got %s:%d:%d, want test.fq:%d:%d", c.name, verr.File, verr.Record, verr.Line, c.record, c.line)
+		}
+	}
+}
@@ -2,7 +2,6 @@ package io
 
 import (
 	"bufio"
-	"compress/gzip"
 	"fmt"
 	"io"
 	"os"
@@ -11,22 +10,32 @@ import (
 	"github.com/fredericlemoine/fastqutils/fastq"
 )
 
+// WriteEntry writes entry in FASTQ format.
 func WriteEntry(w *bufio.Writer, entry *fastq.FastqEntry) {
-	w.WriteString(
-		fmt.Sprintf("%s\n%s\n+\n%s\n",
-			entry.Name,
-			entry.Sequence,
-			entry.Quality,
-		),
-	)
+	w.Write(entry.Name)
+	w.WriteByte('\n')
+	w.Write(entry.Sequence)
+	w.WriteString("\n+\n")
+	w.Write(entry.Quality)
+	w.WriteByte('\n')
 }
 
+// WriteEntryFasta writes entry in FASTA format. The leading '@' of
+// FASTQ names is replaced by '>'.
 func WriteEntryFasta(w *bufio.Writer, entry *fastq.FastqEntry) {
-	w.WriteString(fmt.Sprintf(">%s\n%s\n", entry.Name, entry.Sequence))
+	name := entry.Name
+	if len(name) > 0 && name[0] == '@' {
+		name = name[1:]
+	}
+	w.WriteByte('>')
+	w.Write(name)
+	w.WriteByte('\n')
+	w.Write(entry.Sequence)
+	w.WriteByte('\n')
 }
 
 // multiCloser flushes the buffered writer and then closes, in order,
-// every underlying resource that needs it: a gzip stream and its file,
+// every underlying resource that needs it: a compressed stream and its file,
 // a plain file, or a dsrc compression subprocess. Callers should call
 // Close exactly once when they are done writing.
 type multiCloser struct {
@@ -52,20 +61,18 @@ func (m *multiCloser) Close() error {
 // GetWriter opens file for writing FASTQ (or FASTA) data and returns a
 // buffered writer plus a Closer. The Closer must be called once writing
 // is finished (e.g. via defer): it flushes any buffered data and then
-// finalizes/closes whatever is underneath — a plain file, a gzip
+// finalizes/closes whatever is underneath — a plain file, a compressed
 // stream, or a dsrc compression subprocess.
 //
-// At most one of gziped/dsrced may be true; requesting both is an
-// error. When gziped is true, ".gz" is appended to file, mirroring the
-// previous behavior. When dsrced is true, ".dsrc" is appended to file;
-// because DSRC archives are written directly to a real file (not a
-// stream), file may not be "stdout" or "-" in that case.
-func GetWriter(file string, gziped, dsrced bool) (w *bufio.Writer, closer io.Closer, err error) {
-	if gziped && dsrced {
-		return nil, nil, fmt.Errorf("--gz and --dsrc are mutually exclusive: choose at most one output compression")
-	}
-
-	if dsrced {
+// comp is the output compression format (PLAIN, GZIP, BGZF, BZIP2,
+// XZ, ZSTD or DSRC, see CompressionFromString); the corresponding
+// extension (see CompressionExtension) is appended to file. Because
+// DSRC archives are written directly to a real file (not a stream),
+// file may not be "stdout" or "-" with DSRC compression. With BGZF
+// compression, the .gzi index of the output file (see GziIndex) is
+// written next to it, unless the output is stdout.
+func GetWriter(file string, comp int) (w *bufio.Writer, closer io.Closer, err error) {
+	if comp == DSRC {
 		if file == "stdout" || file == "-" {
 			return nil, nil, fmt.Errorf("dsrc output cannot be streamed to stdout: DSRC archives are written directly to a file, please provide a real --output file name")
 		}
@@ -76,26 +83,102 @@ func GetWriter(file string, gziped, dsrced bool) (w *bufio.Writer, closer io.Clo
 		return w, &multiCloser{w: w, closers: []io.Closer{dc}}, nil
 	}
 
-	ext := ""
-	if gziped {
-		ext = ".gz"
-	}
-
 	var fi *os.File
+	var cw io.WriteCloser
+
 	if file == "stdout" || file == "-" {
 		fi = os.Stdout
 	} else {
-		if fi, err = os.Create(file + ext); err != nil {
+		if fi, err = os.Create(file + CompressionExtension(comp)); err != nil {
 			return nil, nil, err
 		}
 	}
 
-	if gziped {
-		gw := gzip.NewWriter(fi)
-		w = bufio.NewWriter(gw)
-		return w, &multiCloser{w: w, closers: []io.Closer{gw, fi}}, nil
+	if comp == PLAIN {
+		w = bufio.NewWriter(fi)
+		return w, &multiCloser{w: w, closers: []io.Closer{fi}}, nil
+	}
+
+	if comp == BGZF {
+		var bw io.Writer
+		var bc io.Closer
+		gzi := ""
+		if fi != os.Stdout {
+			gzi = fi.Name() + GziExtension
+		}
+		bw, bc = bgzfWriter(fi, gzi)
+		w = bufio.NewWriter(bw)
+		return w, &multiCloser{w: w, closers: []io.Closer{bc}}, nil
+	}
+
+	if cw, err = compressor(fi, comp); err != nil {
+		fi.Close()
+		return nil, nil, err
+	}
+	w = bufio.NewWriter(cw)
+	return w, &multiCloser{w: w, closers: []io.Closer{cw, fi}}, nil
+}
+
+// pairWriter writes first reads to w1 and second reads to w2 (if
+// any), formatting each record with write.
+type pairWriter struct {
+	w1, w2           *bufio.Writer
+	closer1, closer2 io.Closer
+	write            func(w *bufio.Writer, entry *fastq.FastqEntry)
+}
+
+// newPairWriter opens output1, and output2 if it is not "none", with
+// GetWriter.
+func newPairWriter(output1, output2 string, comp int, write func(w *bufio.Writer, entry *fastq.FastqEntry)) (pw *pairWriter, err error) {
+	pw = &pairWriter{write: write}
+	if pw.w1, pw.closer1, err = GetWriter(output1, comp); err != nil {
+		return nil, err
+	}
+	if output2 != "none" {
+		if pw.w2, pw.closer2, err = GetWriter(output2, comp); err != nil {
+			pw.closer1.Close()
+			return nil, err
+		}
+	}
+	return
+}
+
+// Write writes entry1 to the first output, and entry2 to the second
+// output. entry2 is ignored if it is nil or if no second output was
+// given. Write errors are sticky and are reported by Close.
+func (pw *pairWriter) Write(entry1 *fastq.FastqEntry, entry2 *fastq.FastqEntry) error {
+	pw.write(pw.w1, entry1)
+	if pw.w2 != nil && entry2 != nil {
+		pw.write(pw.w2, entry2)
 	}
+	return nil
+}
 
-	w = bufio.NewWriter(fi)
-	return w, &multiCloser{w: w, closers: []io.Closer{fi}}, nil
+// Close flushes and closes the output(s).
+func (pw *pairWriter) Close() (err error) {
+	if err = pw.closer1.Close(); err != nil {
+		return
+	}
+	if pw.closer2 != nil {
+		err = pw.closer2.Close()
+	}
+	return
+}
+
+// FastqWriter is a Writer producing FASTQ file(s).
+type FastqWriter struct {
+	*pairWriter
+}
+
+// NewFastqWriter returns a Writer that writes first reads in FASTQ
+// format to output1 and second reads to output2. If output2 is "none",
+// second reads are not written. comp is the output compression
+// format, as in GetWriter.
+func NewFastqWriter(output1, output2 string, comp int) (fw *FastqWriter, err error) {
+	var pw *pairWriter
+	if pw, err = newPairWriter(output1, output2, comp, WriteEntry); err != nil {
+		return
+	}
+	fw = &FastqWriter{pw}
+	return
 }
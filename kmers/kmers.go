@@ -0,0 +1,334 @@
+// Package kmers counts the k-mers of sequencing reads, finds the most
+// over-represented ones and their positional enrichment, and reads
+// and writes k-mer count tables.
+package kmers
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	goio "io"
+	"math"
+	"sort"
+)
+
+// MaxK is the maximum length of counted k-mers, encoded with 2 bits
+// per base in a uint64.
+const MaxK = 32
+
+// DefaultMaxPositional is the default number of distinct k-mers whose
+// positions in reads are counted.
+const DefaultMaxPositional = 100000
+
+// Count table formats
+const (
+	FORMAT_TSV = iota
+	FORMAT_BINARY
+)
+
+// binaryMagic starts count tables in binary format.
+var binaryMagic = [8]byte{'F', 'Q', 'U', 'K', 'M', 'E', 'R', 'S'}
+
+// FormatFromString returns the count table format corresponding to the
+// given name (tsv or binary).
+func FormatFromString(format string) (f int, err error) {
+	switch format {
+	case "tsv":
+		f = FORMAT_TSV
+	case "binary":
+		f = FORMAT_BINARY
+	default:
+		err = fmt.Errorf("this k-mer table format does not exist : %s, possible values are : tsv, binary", format)
+	}
+	return
+}
+
+// Counter counts the k-mers of reads. K-mers containing other
+// characters than A, C, G and T (case insensitive) are ignored. If
+// Canonical is true, a k-mer and its reverse complement are counted
+// together, as the lowest of both.
+//
+// The number of occurrences of k-mers at every position of reads is
+// also counted, for the first MaxPositional distinct k-mers only, which
+// bounds the memory used. Over-represented k-mers are generally frequent
+// enough to be found among them.
+type Counter struct {
+	K             int   // Length of k-mers
+	Canonical     bool  // Count canonical k-mers
+	MaxPositional int   // Maximum number of k-mers whose positions are counted
+	Total         int64 // Number of counted k-mers
+
+	counts     map[uint64]int64
+	positions  map[uint64][]int64 // positions[code][p]: occurrences starting at position p
+	atPosition []int64            // atPosition[p]: number of k-mers starting at position p
+}
+
+// NewCounter returns an empty Counter of k-mers of length k.
+func NewCounter(k int, canonical bool) (*Counter, error) {
+	if k < 1 || k > MaxK {
+		return nil, fmt.Errorf("k-mer length must be between 1 and %d : %d", MaxK, k)
+	}
+	return &Counter{
+		K:             k,
+		Canonical:     canonical,
+		MaxPositional: DefaultMaxPositional,
+		counts:        make(map[uint64]int64),
+		positions:     make(map[uint64][]int64),
+	}, nil
+}
+
+// code returns the 2 bits code of a base, and false if it is not A, C,
+// G or T.
+func code(b byte) (uint64, bool) {
+	switch b {
+	case 'A', 'a':
+		return 0, true
+	case 'C', 'c':
+		return 1, true
+	case 'G', 'g':
+		return 2, true
+	case 'T', 't':
+		return 3, true
+	}
+	return 0, false
+}
+
+// Add counts the k-mers of seq.
+func (c *Counter) Add(seq []byte) {
+	var fwd, rc uint64
+	// When K is 32, the shift gives 0, and the mask keeps every bit
+	mask := uint64(1)<<(2*uint(c.K)) - 1
+	shift := 2 * uint(c.K-1)
+	valid := 0
+	for i, b := range seq {
+		nt, ok := code(b)
+		if !ok {
+			valid = 0
+			continue
+		}
+		fwd = (fwd<<2 | nt) & mask
+		rc = rc>>2 | (3-nt)<<shift
+		if valid++; valid < c.K {
+			continue
+		}
+		kmer := fwd
+		if c.Canonical && rc < fwd {
+			kmer = rc
+		}
+		c.addKmer(kmer, i-c.K+1)
+	}
+}
+
+// addKmer counts one occurrence of kmer, at position pos.
+func (c *Counter) addKmer(kmer uint64, pos int) {
+	c.counts[kmer]++
+	c.Total++
+	for len(c.atPosition) <= pos {
+		c.atPosition = append(c.atPosition, 0)
+	}
+	c.atPosition[pos]++
+	pc, ok := c.positions[kmer]
+	if !ok && len(c.positions) >= c.MaxPositional {
+		return
+	}
+	for len(pc) <= pos {
+		pc = append(pc, 0)
+	}
+	pc[pos]++
+	c.positions[kmer] = pc
+}
+
+// Len returns the number of distinct k-mers.
+func (c *Counter) Len() int {
+	return len(c.counts)
+}
+
+// Count returns the number of occurrences of kmer (and of its reverse
+// complement, if Canonical is true).
+func (c *Counter) Count(kmer string) int64 {
+	if len(kmer) != c.K {
+		return 0
+	}
+	var fwd, rc uint64
+	for i := 0; i < len(kmer); i++ {
+		nt, ok := code(kmer[i])
+		if !ok {
+			return 0
+		}
+		fwd = fwd<<2 | nt
+		rc = rc | (3-nt)<<(2*uint(i))
+	}
+	if c.Canonical && rc < fwd {
+		fwd = rc
+	}
+	return c.counts[fwd]
+}
+
+// decode returns the sequence of a k-mer code.
+func (c *Counter) decode(kmer uint64) string {
+	seq := make([]byte, c.K)
+	for i := c.K - 1; i >= 0; i-- {
+		seq[i] = "ACGT"[kmer&3]
+		kmer >>= 2
+	}
+	return string(seq)
+}
+
+// Kmer is an over-represented k-mer.
+type Kmer struct {
+	Sequence   string  // Sequence of the k-mer
+	Count      int64   // Number of occurrences
+	Fraction   float64 // Fraction of all k-mers
+	Enrichment float64 // Maximum ratio of observed to expected occurrences at one position (NaN if positions are not counted)
+	Position   int     // Position of the maximum enrichment (starting at 0, -1 if positions are not counted)
+}
+
+// Top returns the n most frequent k-mers, by decreasing number of
+// occurrences, with their positional enrichment: at every position p,
+// a k-mer is expected count*N(p)/Total times, where N(p) is the number
+// of k-mers starting at p. Only positions covered by at least half as
+// many k-mers as the most covered position are considered, so that
+// the few long reads do not give spurious enrichments. A negative n
+// is treated as 0.
+func (c *Counter) Top(n int) (top []Kmer) {
+	n = max(n, 0)
+	codes := make([]uint64, 0, len(c.counts))
+	for kmer := range c.counts {
+		codes = append(codes, kmer)
+	}
+	// Ties are broken by sequence, so that the result is deterministic
+	sort.Slice(codes, func(i, j int) bool {
+		ci, cj := c.counts[codes[i]], c.counts[codes[j]]
+		return ci > cj || ci == cj && codes[i] < codes[j]
+	})
+	if len(codes) > n {
+		codes = codes[:n]
+	}
+
+	var maxAt int64
+	for _, m := range c.atPosition {
+		maxAt = max(maxAt, m)
+	}
+	for _, kmer := range codes {
+		k := Kmer{
+			Sequence:   c.decode(kmer),
+			Count:      c.counts[kmer],
+			Fraction:   float64(c.counts[kmer]) / float64(c.Total),
+			Enrichment: math.NaN(),
+			Position:   -1,
+		}
+		if pc, ok := c.positions[kmer]; ok {
+			k.Enrichment = 0
+			for p, obs := range pc {
+				if 2*c.atPosition[p] < maxAt {
+					continue
+				}
+				expected := float64(k.Count) * float64(c.atPosition[p]) / float64(c.Total)
+				if e := float64(obs) / expected; e > k.Enrichment {
+					k.Enrichment, k.Position = e, p
+				}
+			}
+		}
+		top = append(top, k)
+	}
+	return
+}
+
+// sortedCodes returns the codes of counted k-mers, sorted, i.e. in
+// the lexicographic order of their sequences.
+func (c *Counter) sortedCodes() []uint64 {
+	codes := make([]uint64, 0, len(c.counts))
+	for kmer := range c.counts {
+		codes = append(codes, kmer)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+	return codes
+}
+
+// WriteTable writes the count of every k-mer in the given format.
+func (c *Counter) WriteTable(w goio.Writer, format int) error {
+	switch format {
+	case FORMAT_TSV:
+		return c.WriteTSV(w)
+	case FORMAT_BINARY:
+		return c.WriteBinary(w)
+	}
+	return fmt.Errorf("unknown k-mer table format : %d", format)
+}
+
+// WriteTSV writes the count of every k-mer, as a tab separated table
+// with two columns, kmer and count, sorted by k-mer.
+func (c *Counter) WriteTSV(w goio.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "kmer\tcount")
+	for _, kmer := range c.sortedCodes() {
+		fmt.Fprintf(bw, "%s\t%d\n", c.decode(kmer), c.counts[kmer])
+	}
+	return bw.Flush()
+}
+
+// WriteBinary writes the count of every k-mer in a compact binary
+// format, with little-endian integers:
+//
+//	magic      8 bytes, "FQUKMERS"
+//	k          uint8
+//	canonical  uint8, 1 if k-mers are canonical, 0 otherwise
+//	total      uint64, number of counted k-mers
+//	n          uint64, number of distinct k-mers
+//	n times:   uint64 k-mer code, uint64 count
+//
+// K-mer codes use 2 bits per base (A=0, C=1, G=2, T=3), the first base
+// being the most significant, and are sorted. Positions of k-mers are
+// not written.
+func (c *Counter) WriteBinary(w goio.Writer) error {
+	bw := bufio.NewWriter(w)
+	canonical := uint8(0)
+	if c.Canonical {
+		canonical = 1
+	}
+	header := []interface{}{binaryMagic, uint8(c.K), canonical, uint64(c.Total), uint64(len(c.counts))}
+	for _, v := range header {
+		if err := binary.Write(bw, binary.LittleEndian, v); err != nil {
+			return err
+		}
+	}
+	var buf [16]byte
+	for _, kmer := range c.sortedCodes() {
+		binary.LittleEndian.PutUint64(buf[:8], kmer)
+		binary.LittleEndian.PutUint64(buf[8:], uint64(c.counts[kmer]))
+		if _, err := bw.Write(buf[:]); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// ReadBinary reads a count table written by WriteBinary. The returned
+// Counter has no positional counts.
+func ReadBinary(r goio.Reader) (c *Counter, err error) {
+	var magic [8]byte
+	var k, canonical uint8
+	var total, n uint64
+
+	br := bufio.NewReader(r)
+	for _, v := range []interface{}{&magic, &k, &canonical, &total, &n} {
+		if err = binary.Read(br, binary.LittleEndian, v); err != nil {
+			return nil, fmt.Errorf("malformed k-mer table: %v", err)
+		}
+	}
+	if magic != binaryMagic {
+		return nil, fmt.Errorf("malformed k-mer table: not a fastqutils k-mer table")
+	}
+	if c, err = NewCounter(int(k), canonical == 1); err != nil {
+		return
+	}
+	c.Total = int64(total)
+	var buf [16]byte
+	for i := uint64(0); i < n; i++ {
+		if _, err = goio.ReadFull(br, buf[:]); err != nil {
+			return nil, fmt.Errorf("malformed k-mer table: %v", err)
+		}
+		c.counts[binary.LittleEndian.Uint64(buf[:8])] = int64(binary.LittleEndian.Uint64(buf[8:]))
+	}
+	return
+}
@@ -0,0 +1,101 @@
+package kmers
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestCounter(t *testing.T) {
+	c, err := NewCounter(3, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Add([]byte("ACGTNACGa"))
+	// ACG, CGT, ACG (with lower case), CGA
+	if c.Total != 4 || c.Len() != 3 || c.Count("ACG") != 2 || c.Count("CGA") != 1 || c.Count("CGT") != 1 {
+		t.Errorf("got %d k-mers, %d distinct, ACG %d, CGA %d, CGT %d", c.Total, c.Len(), c.Count("ACG"), c.Count("CGA"), c.Count("CGT"))
+	}
+
+	// CGT is the reverse complement of ACG
+	c, _ = NewCounter(3, true)
+	c.Add([]byte("ACGTNACGa"))
+	if c.Count("ACG") != 3 || c.Count("CGT") != 3 || c.Len() != 2 {
+		t.Errorf("canonical: got ACG %d, CGT %d, %d distinct", c.Count("ACG"), c.Count("CGT"), c.Len())
+	}
+
+	c, _ = NewCounter(32, true)
+	seq := strings.Repeat("ACGTTGCA", 4)
+	c.Add([]byte(seq))
+	if c.Count(seq) != 1 {
+		t.Errorf("k=32: got %d, want 1", c.Count(seq))
+	}
+
+	if _, err = NewCounter(33, false); err == nil {
+		t.Errorf("k=33 must be rejected")
+	}
+}
+
+func TestTop(t *testing.T) {
+	c, _ := NewCounter(4, false)
+	// TTTT is frequent everywhere, GGCC only at the beginning of reads
+	for i := 0; i < 100; i++ {
+		c.Add([]byte("TTTTTTTTTTTT"))
+		if i%4 == 0 {
+			c.Add([]byte("GGCCAAAAAAAA"))
+		}
+	}
+	top := c.Top(2)
+	if len(top) != 2 || top[0].Sequence != "TTTT" || top[1].Sequence != "AAAA" {
+		t.Fatalf("unexpected top k-mers: %v", top)
+	}
+	if top[0].Count != 900 || math.Abs(top[0].Fraction-900.0/1125) > 1e-9 || top[0].Enrichment > 1.5 {
+		t.Errorf("unexpected TTTT statistics: %+v", top[0])
+	}
+	ggcc := c.Top(10)
+	for _, k := range ggcc {
+		if k.Sequence == "GGCC" && (k.Position != 0 || k.Enrichment < 5) {
+			t.Errorf("GGCC must be enriched at position 0: %+v", k)
+		}
+	}
+	if top = c.Top(-1); len(top) != 0 {
+		t.Errorf("got %d k-mers for a negative n", len(top))
+	}
+}
+
+func TestWriteTable(t *testing.T) {
+	c, _ := NewCounter(5, true)
+	c.Add([]byte("ACGTACGGTACCATTGACAGT"))
+
+	var tsv bytes.Buffer
+	if err := c.WriteTable(&tsv, FORMAT_TSV); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(tsv.String()), "\n")
+	if lines[0] != "kmer\tcount" || len(lines) != c.Len()+1 {
+		t.Errorf("unexpected tsv table:\n%s", tsv.String())
+	}
+
+	var bin bytes.Buffer
+	if err := c.WriteTable(&bin, FORMAT_BINARY); err != nil {
+		t.Fatal(err)
+	}
+	c2, err := ReadBinary(&bin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c2.K != c.K || c2.Canonical != c.Canonical || c2.Total != c.Total || c2.Len() != c.Len() {
+		t.Errorf("got k=%d canonical=%v total=%d len=%d, want k=%d canonical=%v total=%d len=%d",
+			c2.K, c2.Canonical, c2.Total, c2.Len(), c.K, c.Canonical, c.Total, c.Len())
+	}
+	for kmer, n := range c.counts {
+		if c2.counts[kmer] != n {
+			t.Errorf("k-mer %s: got %d, want %d", c.decode(kmer), c2.counts[kmer], n)
+		}
+	}
+
+	if _, err = ReadBinary(strings.NewReader("not a k-mer table")); err == nil {
+		t.Errorf("malformed table must be rejected")
+	}
+}
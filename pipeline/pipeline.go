@@ -0,0 +1,169 @@
+// Package pipeline processes sequencing records on several goroutines
+// while keeping their original order.
+//
+// Records are read from an io.Reader in batches, each batch is given
+// to one of the workers, which applies a Func to every record (or pair
+// of records) of the batch, and kept records are finally given, in the
+// input order, to a sink function (typically the Write method of an
+// io.Writer). Output is therefore deterministic whatever the number of
+// workers.
+package pipeline
+
+import (
+	goio "io"
+	"sync"
+
+	"github.com/fredericlemoine/fastqutils/fastq"
+	"github.com/fredericlemoine/fastqutils/io"
+)
+
+// DefaultBatchSize is the number of records (or pairs of records)
+// given at once to a worker.
+const DefaultBatchSize = 1024
+
+// Func transforms entry1 and entry2 (nil for single-end input) in
+// place, and returns false if they must be discarded.
+//
+// Func is called concurrently on different records: it must not
+// modify any shared state without synchronisation.
+type Func func(entry1, entry2 *fastq.FastqEntry) (keep bool, err error)
+
+// Sink receives kept records, in the input order. It is never called
+// concurrently.
+type Sink func(entry1, entry2 *fastq.FastqEntry) error
+
+type batch struct {
+	entries1 []*fastq.FastqEntry
+	entries2 []*fastq.FastqEntry
+	keep     []bool
+	err      error         // Error while reading or processing the batch
+	done     chan struct{} // Closed once the batch has been processed
+}
+
+// process applies f to every record of b, stopping at the first error.
+// An error returned by f replaces the reading error of b, if any, since
+// it concerns an earlier record.
+func (b *batch) process(f Func) {
+	defer close(b.done)
+	b.keep = make([]bool, len(b.entries1))
+	for i := range b.entries1 {
+		keep, err := f(b.entries1[i], b.entries2[i])
+		if err != nil {
+			b.err = err
+			b.keep = b.keep[:i]
+			return
+		}
+		b.keep[i] = keep
+	}
+}
+
+// Run reads every record of r, applies f to them using the given
+// number of workers, and calls sink on kept records, in the input
+// order. It stops at the first error returned by r, f or sink.
+// Reaching the end of r is not an error.
+//
+// If workers is less than 2, everything is done on the calling
+// goroutine, and Run is equivalent to io.ForEach.
+func Run(r io.Reader, workers int, f Func, sink Sink) error {
+	if workers < 2 {
+		return io.ForEach(r, func(entry1, entry2 *fastq.FastqEntry) error {
+			keep, err := f(entry1, entry2)
+			if err != nil || !keep {
+				return err
+			}
+			return sink(entry1, entry2)
+		})
+	}
+	return RunBatch(r, workers, DefaultBatchSize, f, sink)
+}
+
+// RunBatch is Run, with a given number of records per batch.
+// It starts workers processing goroutines plus one reading goroutine,
+// even if workers is 1.
+func RunBatch(r io.Reader, workers, batchSize int, f Func, sink Sink) (err error) {
+	if workers < 1 {
+		workers = 1
+	}
+	if batchSize < 1 {
+		batchSize = DefaultBatchSize
+	}
+
+	// jobs feeds the workers, and ordered gives the batches to the
+	// sink in the input order. Both are bounded so that at most about
+	// 2*workers batches are in memory.
+	jobs := make(chan *batch, workers)
+	ordered := make(chan *batch, 2*workers)
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer close(stop)
+
+	// Reader
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(ordered)
+		defer close(jobs)
+		for {
+			b := &batch{
+				entries1: make([]*fastq.FastqEntry, 0, batchSize),
+				entries2: make([]*fastq.FastqEntry, 0, batchSize),
+				done:     make(chan struct{}),
+			}
+			last := false
+			for !last && len(b.entries1) < batchSize {
+				entry1, entry2, err := r.Next()
+				if err != nil {
+					if err != goio.EOF {
+						b.err = err
+					}
+					last = true
+					break
+				}
+				b.entries1 = append(b.entries1, entry1)
+				b.entries2 = append(b.entries2, entry2)
+			}
+			select {
+			case ordered <- b:
+			case <-stop:
+				return
+			}
+			select {
+			case jobs <- b:
+			case <-stop:
+				return
+			}
+			if last {
+				return
+			}
+		}
+	}()
+
+	// Workers
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range jobs {
+				b.process(f)
+			}
+		}()
+	}
+
+	// Sink, in order
+	for b := range ordered {
+		<-b.done
+		for i, keep := range b.keep {
+			if !keep {
+				continue
+			}
+			if err = sink(b.entries1[i], b.entries2[i]); err != nil {
+				return
+			}
+		}
+		if b.err != nil {
+			return b.err
+		}
+	}
+	return
+}
@@ -0,0 +1,107 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/fredericlemoine/fastqutils/fastq"
+	"github.com/fredericlemoine/fastqutils/io"
+)
+
+func entries(n int) (entries1, entries2 []*fastq.FastqEntry) {
+	for i := 0; i < n; i++ {
+		entries1 = append(entries1, &fastq.FastqEntry{Name: []byte(fmt.Sprintf("@read%d/1", i)), Sequence: []byte("ACGT"), Quality: []byte("IIII")})
+		entries2 = append(entries2, &fastq.FastqEntry{Name: []byte(fmt.Sprintf("@read%d/2", i)), Sequence: []byte("TTGG"), Quality: []byte("IIII")})
+	}
+	return
+}
+
+// errReader returns an error after the records of its MemoryReader
+type errReader struct {
+	*io.MemoryReader
+}
+
+var errRead = errors.New("read error")
+
+func (r errReader) Next() (entry1, entry2 *fastq.FastqEntry, err error) {
+	if entry1, entry2, err = r.MemoryReader.Next(); err != nil {
+		err = errRead
+	}
+	return
+}
+
+func TestRunOrder(t *testing.T) {
+	for _, workers := range []int{1, 2, 8} {
+		for _, batchSize := range []int{1, 7, 1000} {
+			entries1, entries2 := entries(500)
+			// Keeps even records only, after changing their sequence
+			f := func(entry1, entry2 *fastq.FastqEntry) (bool, error) {
+				time.Sleep(time.Duration(rand.Intn(20)) * time.Microsecond)
+				entry1.Sequence[0] = 'N'
+				var i int
+				fmt.Sscanf(string(entry1.Name), "@read%d/1", &i)
+				return i%2 == 0, nil
+			}
+			mw := io.NewMemoryWriter()
+			if err := RunBatch(io.NewMemoryReader(entries1, entries2), workers, batchSize, f, mw.Write); err != nil {
+				t.Fatal(err)
+			}
+			if len(mw.Entries1) != 250 || len(mw.Entries2) != 250 {
+				t.Fatalf("workers=%d, batch=%d: got %d records, want 250", workers, batchSize, len(mw.Entries1))
+			}
+			for i := range mw.Entries1 {
+				if mw.Entries1[i] != entries1[2*i] || mw.Entries2[i] != entries2[2*i] {
+					t.Fatalf("workers=%d, batch=%d: record %d is %s, want %s", workers, batchSize, i, mw.Entries1[i].Name, entries1[2*i].Name)
+				}
+				if string(mw.Entries1[i].Sequence) != "NCGT" {
+					t.Errorf("record %d has not been transformed", i)
+				}
+			}
+		}
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	errFunc := errors.New("func error")
+	errSink := errors.New("sink error")
+	keep := func(entry1, entry2 *fastq.FastqEntry) (bool, error) { return true, nil }
+
+	for _, workers := range []int{1, 4} {
+		// Error of the reader, after all records have been written
+		entries1, entries2 := entries(100)
+		n := 0
+		err := RunBatch(errReader{io.NewMemoryReader(entries1, entries2)}, workers, 16, keep, func(entry1, entry2 *fastq.FastqEntry) error {
+			n++
+			return nil
+		})
+		if err != errRead || n != 100 {
+			t.Errorf("workers=%d: got %v after %d records, want %v after 100", workers, err, n, errRead)
+		}
+
+		// Error of the function, before the reader error
+		n = 0
+		err = RunBatch(errReader{io.NewMemoryReader(entries1, entries2)}, workers, 16, func(entry1, entry2 *fastq.FastqEntry) (bool, error) {
+			if string(entry1.Name) == "@read50/1" {
+				return false, errFunc
+			}
+			return true, nil
+		}, func(entry1, entry2 *fastq.FastqEntry) error {
+			n++
+			return nil
+		})
+		if err != errFunc || n != 50 {
+			t.Errorf("workers=%d: got %v after %d records, want %v after 50", workers, err, n, errFunc)
+		}
+
+		// Error of the sink
+		err = RunBatch(io.NewMemoryReader(entries(10000)), workers, 16, keep, func(entry1, entry2 *fastq.FastqEntry) error {
+			return errSink
+		})
+		if err != errSink {
+			t.Errorf("workers=%d: got %v, want %v", workers, err, errSink)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package stats
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/fredericlemoine/fastqutils/fastq"
+	"github.com/fredericlemoine/fastqutils/trim"
+)
+
+// KnownAdapters are the adapter k-mers searched by AdapterContent
+// (the same as FastQC). TruSeq read 1 and read 2 adapters share the
+// Illumina Universal k-mer.
+var KnownAdapters = []trim.Adapter{
+	{Name: "Illumina Universal", Sequence: []byte("AGATCGGAAGAG")},
+	{Name: "Illumina Small RNA 3'", Sequence: []byte("TGGAATTCTCGG")},
+	{Name: "Nextera Transposase", Sequence: []byte("CTGTCTCTTATA")},
+	{Name: "SOLiD Small RNA", Sequence: []byte("CGCCTTGGCCGT")},
+}
+
+// MinAdapterContent is the fraction of reads containing a known
+// adapter above which the adapter is considered found.
+const MinAdapterContent = 0.01
+
+const (
+	adapterK         = 12     // Length of k-mers used to infer adapters
+	adapterTail      = 32     // k-mers are counted in the last adapterTail bases of reads
+	adapterSample    = 100000 // k-mers are counted in the first adapterSample reads
+	adapterMinInfer  = 0.005  // Minimum frequency of an inferred adapter k-mer
+	adapterMaxLength = 40     // Maximum length of an inferred adapter
+)
+
+// AdapterContent is a Module counting, for every read position, the
+// number of reads in which a known adapter starts at this position.
+//
+// It also counts the k-mers found at the 3' end of the first reads,
+// to infer the sequence of an unknown adapter (see Infer).
+type AdapterContent struct {
+	Adapters []trim.Adapter // Searched adapters (KnownAdapters by default)
+	NReads   int64          // Number of reads
+	Length   int            // Length of the longest read
+	starts   [][]int64      // starts[a][p]: number of reads where adapter a starts at p
+	kmers    map[uint32]int // Counts of 3' k-mers of sampled reads
+	sampled  int            // Number of reads whose k-mers are counted
+}
+
+// NewAdapterContent returns an AdapterContent searching KnownAdapters.
+func NewAdapterContent() *AdapterContent {
+	ac := &AdapterContent{Adapters: KnownAdapters}
+	ac.initCounts()
+	return ac
+}
+
+// initCounts allocates the counters of the adapters, so that
+// AdapterContents built without NewAdapterContent, or whose Adapters
+// are changed after construction, can be used.
+func (ac *AdapterContent) initCounts() {
+	if ac.Adapters == nil {
+		ac.Adapters = KnownAdapters
+	}
+	for len(ac.starts) < len(ac.Adapters) {
+		ac.starts = append(ac.starts, nil)
+	}
+	if ac.kmers == nil {
+		ac.kmers = make(map[uint32]int)
+	}
+}
+
+// Add implements Module.
+func (ac *AdapterContent) Add(entry *fastq.FastqEntry) {
+	ac.initCounts()
+	seq := entry.Sequence
+	ac.NReads++
+	ac.Length = max(ac.Length, len(seq))
+	for i, a := range ac.Adapters {
+		if p := bytes.Index(seq, a.Sequence); p >= 0 {
+			for len(ac.starts[i]) <= p {
+				ac.starts[i] = append(ac.starts[i], 0)
+			}
+			ac.starts[i][p]++
+		}
+	}
+	if ac.sampled < adapterSample {
+		ac.sampled++
+		for p := max(0, len(seq)-adapterTail); p+adapterK <= len(seq); p++ {
+			if code, ok := encodeKmer(seq[p : p+adapterK]); ok {
+				ac.kmers[code]++
+			}
+		}
+	}
+}
+
+// Content returns, for every adapter and every read position p, the
+// fraction of reads in which the adapter starts at or before p, as
+// FastQC adapter content.
+func (ac *AdapterContent) Content() [][]float64 {
+	ac.initCounts()
+	length := ac.Length
+	content := make([][]float64, len(ac.Adapters))
+	for i := range ac.Adapters {
+		content[i] = make([]float64, length)
+		var sum int64
+		for p := 0; p < length; p++ {
+			if p < len(ac.starts[i]) {
+				sum += ac.starts[i][p]
+			}
+			if ac.NReads > 0 {
+				content[i][p] = float64(sum) / float64(ac.NReads)
+			}
+		}
+	}
+	return content
+}
+
+// Total returns, for every adapter, the fraction of reads containing it.
+func (ac *AdapterContent) Total() []float64 {
+	ac.initCounts()
+	total := make([]float64, len(ac.Adapters))
+	for i := range ac.Adapters {
+		var sum int64
+		for _, c := range ac.starts[i] {
+			sum += c
+		}
+		if ac.NReads > 0 {
+			total[i] = float64(sum) / float64(ac.NReads)
+		}
+	}
+	return total
+}
+
+// Found returns true if at least one known adapter is found in
+// MinAdapterContent of the reads.
+func (ac *AdapterContent) Found() bool {
+	for _, t := range ac.Total() {
+		if t >= MinAdapterContent {
+			return true
+		}
+	}
+	return false
+}
+
+// Infer returns the most likely adapter sequence, assembled from the
+// most frequent 3' k-mer of sampled reads, and the fraction of sampled
+// reads containing this k-mer. It returns nil if no k-mer is
+// over-represented. Low complexity k-mers (such as poly-A or poly-G
+// tails) are not considered.
+func (ac *AdapterContent) Infer() (adapter []byte, freq float64) {
+	type kmerCount struct {
+		code  uint32
+		count int
+	}
+	var best []kmerCount
+	for code, count := range ac.kmers {
+		if float64(count) >= adapterMinInfer*float64(ac.sampled) && !lowComplexity(code) {
+			best = append(best, kmerCount{code, count})
+		}
+	}
+	if len(best) == 0 {
+		return nil, 0
+	}
+	// Ties are broken by code, so that the result is deterministic
+	sort.Slice(best, func(i, j int) bool {
+		return best[i].count > best[j].count || best[i].count == best[j].count && best[i].code < best[j].code
+	})
+	seed := best[0]
+	adapter = decodeKmer(seed.code)
+	minCount := seed.count / 2
+
+	// Extension to the right, then to the left, with the most frequent
+	// overlapping k-mers, as long as they are frequent enough
+	mask := uint32(1)<<(2*adapterK) - 1
+	for code := seed.code; len(adapter) < adapterMaxLength; {
+		next, count := uint32(0), 0
+		for nt := uint32(0); nt < 4; nt++ {
+			c := (code<<2)&mask | nt
+			if ac.kmers[c] > count {
+				next, count = c, ac.kmers[c]
+			}
+		}
+		if count == 0 || count < minCount {
+			break
+		}
+		adapter = append(adapter, "ACGT"[next&3])
+		code = next
+	}
+	for code := seed.code; len(adapter) < adapterMaxLength; {
+		next, count := uint32(0), 0
+		for nt := uint32(0); nt < 4; nt++ {
+			c := code>>2 | nt<<(2*(adapterK-1))
+			if ac.kmers[c] > count {
+				next, count = c, ac.kmers[c]
+			}
+		}
+		if count == 0 || count < minCount {
+			break
+		}
+		adapter = append([]byte{"ACGT"[next>>(2*(adapterK-1))]}, adapter...)
+		code = next
+	}
+	return adapter, float64(seed.count) / float64(ac.sampled)
+}
+
+// encodeKmer returns the 2 bits per base code of kmer, and false if it
+// contains other characters than A, C, G and T.
+func encodeKmer(kmer []byte) (code uint32, ok bool) {
+	for _, b := range kmer {
+		nt, err := fastq.Index(b)
+		if err != nil || nt > 3 {
+			return 0, false
+		}
+		code = code<<2 | uint32(nt)
+	}
+	return code, true
+}
+
+// decodeKmer returns the sequence of a k-mer code.
+func decodeKmer(code uint32) []byte {
+	kmer := make([]byte, adapterK)
+	for i := adapterK - 1; i >= 0; i-- {
+		kmer[i] = "ACGT"[code&3]
+		code >>= 2
+	}
+	return kmer
+}
+
+// lowComplexity returns true if a k-mer code contains at most 2
+// different nucleotides.
+func lowComplexity(code uint32) bool {
+	var seen [4]bool
+	n := 0
+	for i := 0; i < adapterK; i++ {
+		if !seen[code&3] {
+			seen[code&3] = true
+			n++
+		}
+		code >>= 2
+	}
+	return n <= 2
+}
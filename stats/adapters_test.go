@@ -0,0 +1,88 @@
+package stats
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/fredericlemoine/fastqutils/fastq"
+	"github.com/fredericlemoine/fastqutils/trim"
+)
+
+// adapterReads returns reads of length 100 made of random inserts of
+// 40 to 100 bases, followed by adapter.
+func adapterReads(adapter string, n int) (entries []*fastq.FastqEntry) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < n; i++ {
+		seq := make([]byte, 40+r.Intn(61), 100)
+		for j := range seq {
+			seq[j] = "ACGT"[r.Intn(4)]
+		}
+		seq = append(seq, adapter...)[:100]
+		entries = append(entries, &fastq.FastqEntry{Name: []byte("@read"), Sequence: seq})
+	}
+	return
+}
+
+func TestAdapterContent(t *testing.T) {
+	ac := NewAdapterContent()
+	for _, e := range adapterReads("AGATCGGAAGAGCACACGTCTGAACTCCAGTCA", 1000) {
+		ac.Add(e)
+	}
+	total := ac.Total()
+	// Adapter k-mers are complete in reads with inserts of at most 88 bases
+	if total[0] < 0.7 || total[0] > 0.85 {
+		t.Errorf("Illumina Universal content: got %.2f, want about 0.8", total[0])
+	}
+	for i := 1; i < len(total); i++ {
+		if total[i] > 0.01 {
+			t.Errorf("%s content: got %.2f, want 0", ac.Adapters[i].Name, total[i])
+		}
+	}
+	content := ac.Content()
+	if len(content[0]) != 100 || content[0][39] != 0 || content[0][99] != total[0] {
+		t.Errorf("unexpected adapter content by position: %v", content[0])
+	}
+}
+
+func TestAdapterContentCustom(t *testing.T) {
+	custom := []trim.Adapter{{Name: "Custom", Sequence: []byte("GTTCAGAGTTCT")}}
+	// Adapters set without NewAdapterContent, or after it
+	extended := NewAdapterContent()
+	extended.Adapters = append(custom, KnownAdapters...)
+	for _, ac := range []*AdapterContent{{Adapters: custom}, extended} {
+		for _, e := range adapterReads("GTTCAGAGTTCTACAGTCCGACGATC", 1000) {
+			ac.Add(e)
+		}
+		if total := ac.Total(); len(total) != len(ac.Adapters) || total[0] < 0.7 {
+			t.Errorf("%d adapters: got content %v, want about 0.8 for the custom adapter", len(ac.Adapters), total)
+		}
+	}
+	// The zero value searches KnownAdapters
+	ac := &AdapterContent{}
+	ac.Add(&fastq.FastqEntry{Name: []byte("@read"), Sequence: []byte("ACGTAGATCGGAAGAG")})
+	if total := ac.Total(); len(total) != len(KnownAdapters) || total[0] != 1 {
+		t.Errorf("zero value: got content %v", total)
+	}
+}
+
+func TestInferAdapter(t *testing.T) {
+	unknown := "GTTCAGAGTTCTACAGTCCGACGATCGTAC"
+	ac := NewAdapterContent()
+	for _, e := range adapterReads(unknown, 1000) {
+		ac.Add(e)
+	}
+	adapter, freq := ac.Infer()
+	if !bytes.HasPrefix([]byte(unknown), adapter) || len(adapter) < 20 || freq < 0.1 {
+		t.Errorf("got inferred adapter %s (%.2f), want a prefix of %s", adapter, freq, unknown)
+	}
+
+	// Random reads
+	ac = NewAdapterContent()
+	for _, e := range adapterReads("", 1000) {
+		ac.Add(e)
+	}
+	if adapter, _ = ac.Infer(); adapter != nil {
+		t.Errorf("got inferred adapter %s in random reads", adapter)
+	}
+}
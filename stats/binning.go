@@ -0,0 +1,148 @@
+package stats
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// MaxPhred is the highest Phred score that can be encoded in FASTQ
+// ('~' with an offset of 33) and in BAM files.
+const MaxPhred = 93
+
+// Bin replaces the Phred scores from Min to Max (included) by Value.
+type Bin struct {
+	Min, Max, Value int
+}
+
+// Built-in binning schemes
+var (
+	// Illumina 8-level binning (HiSeq X, HiSeq 4000, NextSeq...)
+	Illumina8Bins = []Bin{{2, 9, 6}, {10, 19, 15}, {20, 24, 22}, {25, 29, 27}, {30, 34, 33}, {35, 39, 37}, {40, MaxPhred, 40}}
+	// NovaSeq (RTA3) 4-level binning
+	NovaSeq4Bins = []Bin{{0, 2, 2}, {3, 14, 12}, {15, 30, 23}, {31, MaxPhred, 37}}
+)
+
+// PresetBins returns the bins of the given built-in binning scheme:
+// illumina8 or novaseq4.
+func PresetBins(scheme string) (bins []Bin, err error) {
+	switch scheme {
+	case "illumina8":
+		bins = Illumina8Bins
+	case "novaseq4":
+		bins = NovaSeq4Bins
+	default:
+		err = fmt.Errorf("this binning scheme does not exist : %s, possible values are : illumina8, novaseq4", scheme)
+	}
+	return
+}
+
+// ReadBins reads a bin table: a tab separated file with three columns,
+// the lowest and highest Phred scores of the bin, and the score
+// replacing them. Empty lines and lines starting with '#' are ignored.
+func ReadBins(file string) (bins []Bin, err error) {
+	var f *os.File
+
+	if f, err = os.Open(file); err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for nline := 1; scanner.Scan(); nline++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		cols := strings.Split(line, "\t")
+		if len(cols) != 3 {
+			return nil, fmt.Errorf("%s, line %d: a bin must have 3 columns (min, max, value), found %d", file, nline, len(cols))
+		}
+		var values [3]int
+		for i, c := range cols {
+			if values[i], err = strconv.Atoi(strings.TrimSpace(c)); err != nil {
+				return nil, fmt.Errorf("%s, line %d: %v", file, nline, err)
+			}
+		}
+		bins = append(bins, Bin{values[0], values[1], values[2]})
+	}
+	if err = scanner.Err(); err != nil {
+		return
+	}
+	if len(bins) == 0 {
+		err = fmt.Errorf("%s: the bin table is empty", file)
+	}
+	return
+}
+
+// QualityBinner replaces the Phred scores of reads by the value of
+// their bin. Scores not covered by any bin are not modified.
+//
+// The mean quality before and after binning is computed on every
+// binned base, with atomic operations, so that Bin may be called
+// concurrently.
+type QualityBinner struct {
+	table                       [MaxPhred + 1]byte // table[q]: binned score of q
+	nbases, sumBefore, sumAfter int64
+}
+
+// NewQualityBinner returns a QualityBinner with the given bins, which
+// must be between 0 and MaxPhred, and must not overlap.
+func NewQualityBinner(bins []Bin) (*QualityBinner, error) {
+	var covered [MaxPhred + 1]bool
+	b := &QualityBinner{}
+	for q := range b.table {
+		b.table[q] = byte(q)
+	}
+	for _, bin := range bins {
+		if bin.Min < 0 || bin.Max > MaxPhred || bin.Min > bin.Max || bin.Value < 0 || bin.Value > MaxPhred {
+			return nil, fmt.Errorf("invalid bin %d-%d:%d, scores must be between 0 and %d", bin.Min, bin.Max, bin.Value, MaxPhred)
+		}
+		for q := bin.Min; q <= bin.Max; q++ {
+			if covered[q] {
+				return nil, fmt.Errorf("bin %d-%d:%d overlaps another bin at score %d", bin.Min, bin.Max, bin.Value, q)
+			}
+			covered[q] = true
+			b.table[q] = byte(bin.Value)
+		}
+	}
+	return b, nil
+}
+
+// Bin bins the quality characters of qual in place, offset being the
+// offset of their encoding (0 for raw Phred scores, as in BAM files).
+// Qualities must be Phred scores: Solexa log-odds scores must be
+// converted first (see QualityConverter).
+// Characters that are not valid Phred scores (e.g. 0xff, for missing
+// BAM qualities) are not modified.
+func (b *QualityBinner) Bin(qual []byte, offset int) {
+	var n, before, after int64
+	for i, c := range qual {
+		q := int(c) - offset
+		if q < 0 || q > MaxPhred {
+			continue
+		}
+		binned := b.table[q]
+		qual[i] = binned + byte(offset)
+		n++
+		before += int64(q)
+		after += int64(binned)
+	}
+	atomic.AddInt64(&b.nbases, n)
+	atomic.AddInt64(&b.sumBefore, before)
+	atomic.AddInt64(&b.sumAfter, after)
+}
+
+// MeanQualities returns the number of binned bases, and their mean
+// quality before and after binning (0 if no base was binned).
+func (b *QualityBinner) MeanQualities() (nbases int64, before, after float64) {
+	if nbases = atomic.LoadInt64(&b.nbases); nbases == 0 {
+		return
+	}
+	before = float64(atomic.LoadInt64(&b.sumBefore)) / float64(nbases)
+	after = float64(atomic.LoadInt64(&b.sumAfter)) / float64(nbases)
+	return
+}
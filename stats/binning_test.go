@@ -0,0 +1,60 @@
+package stats
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestQualityBinner(t *testing.T) {
+	b, err := NewQualityBinner(Illumina8Bins)
+	if err != nil {
+		t.Fatal(err)
+	}
+	qual := []byte("!#+5?EIK~")
+	if nbases, before, after := b.MeanQualities(); nbases != 0 || before != 0 || after != 0 {
+		t.Errorf("got %d bases, mean %.3f before and %.3f after, without binned bases", nbases, before, after)
+	}
+	b.Bin(qual, 33)
+	if string(qual) != "!'07BFIII" {
+		t.Errorf("got %q", qual)
+	}
+	// Raw Phred scores, missing qualities are not modified
+	raw := []byte{2, 25, 41, 0xff}
+	b.Bin(raw, 0)
+	if raw[0] != 6 || raw[1] != 27 || raw[2] != 40 || raw[3] != 0xff {
+		t.Errorf("got %v", raw)
+	}
+	nbases, before, after := b.MeanQualities()
+	if nbases != 12 || before != float64(0+2+10+20+30+36+40+42+93+2+25+41)/12 || after != float64(0+6+15+22+33+37+40+40+40+6+27+40)/12 {
+		t.Errorf("got %d bases, mean %.3f before and %.3f after", nbases, before, after)
+	}
+
+	if _, err = NewQualityBinner([]Bin{{0, 10, 5}, {10, 20, 15}}); err == nil {
+		t.Errorf("overlapping bins must be rejected")
+	}
+	if _, err = NewQualityBinner([]Bin{{10, 5, 5}}); err == nil {
+		t.Errorf("invalid bins must be rejected")
+	}
+}
+
+func TestReadBins(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "bins.tsv")
+	if err := os.WriteFile(file, []byte("# min\tmax\tvalue\n0\t19\t10\n\n20\t93\t30\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	bins, err := ReadBins(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bins) != 2 || bins[0] != (Bin{0, 19, 10}) || bins[1] != (Bin{20, 93, 30}) {
+		t.Errorf("got %v", bins)
+	}
+
+	if err = os.WriteFile(file, []byte("0\t19\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ReadBins(file); err == nil {
+		t.Errorf("bins with 2 columns must be rejected")
+	}
+}
@@ -0,0 +1,80 @@
+package stats
+
+import (
+	"fmt"
+	"math"
+)
+
+// QualityConverter converts quality strings from an encoding to
+// another. Solexa scores are log-odds, and not Phred scores: they are
+// converted with the exact formulas, and not by shifting the offset.
+type QualityConverter struct {
+	From, To Encoding   // Source and destination encodings
+	table    [256]int16 // table[c]: converted quality character, -1 if c is out of range
+}
+
+// NewQualityConverter returns a converter from the encoding from to
+// the encoding to.
+func NewQualityConverter(from, to Encoding) (c *QualityConverter, err error) {
+	var fromSpec, toSpec EncodingSpec
+	var ok bool
+
+	if from == UNKNOWN || to == UNKNOWN {
+		return nil, fmt.Errorf("qualities cannot be converted from or to an unknown encoding")
+	}
+	if fromSpec, ok = from.Spec(); !ok {
+		return nil, fmt.Errorf("this encoding Code does not exist : %d", from)
+	}
+	if toSpec, ok = to.Spec(); !ok {
+		return nil, fmt.Errorf("this encoding Code does not exist : %d", to)
+	}
+
+	c = &QualityConverter{From: from, To: to}
+	for q := range c.table {
+		c.table[q] = -1
+		if q < fromSpec.MinQual || q > fromSpec.MaxQual {
+			continue
+		}
+		score := q - fromSpec.Offset
+		if fromSpec.LogOdds && !toSpec.LogOdds {
+			score = solexaToPhred(score)
+		} else if !fromSpec.LogOdds && toSpec.LogOdds {
+			score = max(phredToSolexa(score), toSpec.MinQual-toSpec.Offset)
+		}
+		if conv := score + toSpec.Offset; conv >= toSpec.MinQual && conv <= toSpec.MaxQual {
+			c.table[q] = int16(conv)
+		}
+	}
+	return
+}
+
+// solexaToPhred converts a Solexa score to the Phred score of the
+// same error probability.
+func solexaToPhred(score int) int {
+	return int(math.Round(10 * math.Log10(math.Pow(10, float64(score)/10)+1)))
+}
+
+// phredToSolexa converts a Phred score to the Solexa score of the
+// same error probability. Phred 0 gives -infinity, i.e. the minimum
+// int: callers bound the result.
+func phredToSolexa(score int) int {
+	if score <= 0 {
+		return math.MinInt32
+	}
+	return int(math.Round(10 * math.Log10(math.Pow(10, float64(score)/10)-1)))
+}
+
+// Convert converts qual in place. It returns an error, and leaves qual
+// unchanged, if a quality character is out of the range of the source
+// encoding, or cannot be represented in the destination encoding.
+func (c *QualityConverter) Convert(qual []byte) error {
+	for _, q := range qual {
+		if c.table[q] < 0 {
+			return fmt.Errorf("quality character %q cannot be converted from %s to %s: out of range", q, c.From, c.To)
+		}
+	}
+	for i, q := range qual {
+		qual[i] = byte(c.table[q])
+	}
+	return nil
+}
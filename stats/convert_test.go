@@ -0,0 +1,36 @@
+package stats
+
+import (
+	"testing"
+)
+
+func TestQualityConverter(t *testing.T) {
+	for _, test := range []struct {
+		from, to Encoding
+		qual     string
+		want     string
+		err      bool
+	}{
+		{ILLUMINA_1_5, ILLUMINA_1_8, "Bh^", "#I?", false},
+		{ILLUMINA_1_8, ILLUMINA_1_3, "!I", "@h", false},
+		{SOLEXA, ILLUMINA_1_8, ";@Jh", "\"$+I", false}, // -5, 0, 10, 40 are Phred 1, 3, 10, 40
+		{ILLUMINA_1_8, SOLEXA, "!\"$+I", ";;@Jh", false},
+		{ILLUMINA_1_8, ILLUMINA_1_8, "!IK", "!IK", false},
+		{ILLUMINA_1_3, ILLUMINA_1_8, "!", "!", true}, // Out of Illumina 1.3 range
+		{LONG_READS, ILLUMINA_1_8, "I~", "I~", true}, // Q93 is out of Illumina 1.8 range
+	} {
+		c, err := NewQualityConverter(test.from, test.to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		qual := []byte(test.qual)
+		err = c.Convert(qual)
+		if string(qual) != test.want || (err != nil) != test.err {
+			t.Errorf("%d to %d: %q gives %q (%v), want %q", test.from, test.to, test.qual, qual, err, test.want)
+		}
+	}
+
+	if _, err := NewQualityConverter(UNKNOWN, SANGER); err == nil {
+		t.Errorf("conversion from an unknown encoding must fail")
+	}
+}
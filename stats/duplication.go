@@ -0,0 +1,173 @@
+package stats
+
+import (
+	"sort"
+
+	"github.com/fredericlemoine/fastqutils/fastq"
+)
+
+// DefaultMaxTracked is the default number of distinct sequences
+// tracked by Duplication.
+const DefaultMaxTracked = 100000
+
+// DuplicationLevels are the lower bounds of the duplication level bins
+// of DuplicationCounter.Levels, and DuplicationLabels their names.
+var (
+	DuplicationLevels = []int64{1, 2, 3, 10, 100, 1000}
+	DuplicationLabels = []string{"1", "2", "3-9", "10-99", "100-999", "1000+"}
+)
+
+// Duplication is a Module estimating the duplication levels of reads
+// and, for paired-end input, of pairs of reads, as FastQC sequence
+// duplication levels.
+//
+// Pairs are duplicates if both of their reads are duplicates, which
+// is the relevant measure of library complexity for paired-end data:
+// reads alone are duplicated more often than pairs.
+type Duplication struct {
+	Reads *DuplicationCounter // Every read (both reads of pairs)
+	Pairs *DuplicationCounter // Pairs of reads, nil for single-end input
+}
+
+// NewDuplication returns an empty Duplication, tracking at most
+// maxTracked distinct reads and maxTracked distinct pairs.
+func NewDuplication(maxTracked int) *Duplication {
+	return &Duplication{
+		Reads: newDuplicationCounter(maxTracked),
+	}
+}
+
+// Add implements Module.
+func (d *Duplication) Add(entry *fastq.FastqEntry) {
+	d.Reads.add(entry.Sequence)
+}
+
+// AddPair implements PairModule. The sequences of both reads are
+// separated by a space.
+func (d *Duplication) AddPair(entry1, entry2 *fastq.FastqEntry) {
+	if d.Pairs == nil {
+		d.Pairs = newDuplicationCounter(d.Reads.MaxTracked)
+	}
+	key := make([]byte, 0, len(entry1.Sequence)+len(entry2.Sequence)+1)
+	key = append(key, entry1.Sequence...)
+	key = append(key, ' ')
+	key = append(key, entry2.Sequence...)
+	d.Pairs.add(key)
+}
+
+// DuplicationCounter counts the occurrences of the first MaxTracked
+// distinct sequences of the input, which bounds the memory used.
+// Sequences seen after MaxTracked distinct sequences are counted only
+// if they are already tracked, and duplication levels are corrected
+// accordingly (see Levels).
+type DuplicationCounter struct {
+	MaxTracked int   // Maximum number of distinct sequences tracked
+	Total      int64 // Number of sequences
+	exact      int64 // Number of sequences seen before the first untracked one
+	counts     map[string]int64
+}
+
+func newDuplicationCounter(maxTracked int) *DuplicationCounter {
+	if maxTracked < 1 {
+		maxTracked = DefaultMaxTracked
+	}
+	return &DuplicationCounter{
+		MaxTracked: maxTracked,
+		counts:     make(map[string]int64),
+	}
+}
+
+// add counts one occurrence of seq.
+func (dc *DuplicationCounter) add(seq []byte) {
+	dc.Total++
+	// The conversion does not allocate in map lookups
+	if _, ok := dc.counts[string(seq)]; ok {
+		dc.counts[string(seq)]++
+	} else if len(dc.counts) < dc.MaxTracked {
+		dc.counts[string(seq)] = 1
+	} else {
+		return
+	}
+	if dc.exact == dc.Total-1 {
+		dc.exact = dc.Total
+	}
+}
+
+// Levels returns, for every bin of DuplicationLevels, the fraction of
+// sequences whose number of occurrences falls in the bin, and the
+// estimated fraction of distinct sequences (i.e. of sequences
+// remaining after deduplication).
+//
+// When not all the distinct sequences could be tracked, the number of
+// distinct sequences of each duplication level is corrected by the
+// probability that such a sequence is seen among the tracked ones,
+// as FastQC does.
+func (dc *DuplicationCounter) Levels() (levels []float64, distinct float64) {
+	levels = make([]float64, len(DuplicationLevels))
+	observed := make(map[int64]int64)
+	for _, c := range dc.counts {
+		observed[c]++
+	}
+	var reads, distincts float64
+	for level, n := range observed {
+		corrected := correctedCount(dc.exact, dc.Total, level, n)
+		bin := sort.Search(len(DuplicationLevels), func(i int) bool { return DuplicationLevels[i] > level }) - 1
+		levels[bin] += corrected * float64(level)
+		reads += corrected * float64(level)
+		distincts += corrected
+	}
+	if reads == 0 {
+		return levels, 0
+	}
+	for i := range levels {
+		levels[i] /= reads
+	}
+	return levels, distincts / reads
+}
+
+// correctedCount returns the estimated number of distinct sequences
+// occurring level times among total sequences, n of them being found
+// among the first exact sequences.
+func correctedCount(exact, total, level, n int64) float64 {
+	if exact == total || total-n < exact {
+		return float64(n)
+	}
+	// Probability of not seeing a sequence occurring level times among
+	// the first exact sequences. Once it is too low to change the
+	// result, it is rounded to 0.
+	pNotSeen := 1.0
+	limit := 1 - float64(n)/(float64(n)+0.01)
+	for i := int64(0); i < exact; i++ {
+		pNotSeen *= float64(total-i-level) / float64(total-i)
+		if pNotSeen < limit {
+			pNotSeen = 0
+			break
+		}
+	}
+	return float64(n) / (1 - pNotSeen)
+}
+
+// OverRepresented is a sequence found several times.
+type OverRepresented struct {
+	Sequence string  // Sequence (for pairs, both sequences separated by a space)
+	Count    int64   // Number of occurrences
+	Fraction float64 // Fraction of all sequences
+}
+
+// OverRepresented returns the n most frequent sequences found at least
+// twice, by decreasing number of occurrences.
+func (dc *DuplicationCounter) OverRepresented(n int) (over []OverRepresented) {
+	for seq, c := range dc.counts {
+		if c > 1 {
+			over = append(over, OverRepresented{seq, c, float64(c) / float64(dc.Total)})
+		}
+	}
+	// Ties are broken by sequence, so that the result is deterministic
+	sort.Slice(over, func(i, j int) bool {
+		return over[i].Count > over[j].Count || over[i].Count == over[j].Count && over[i].Sequence < over[j].Sequence
+	})
+	if len(over) > n {
+		over = over[:n]
+	}
+	return
+}
@@ -0,0 +1,78 @@
+package stats
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/fredericlemoine/fastqutils/fastq"
+	"github.com/fredericlemoine/fastqutils/io"
+)
+
+// dupReads returns 5000 reads of length 50: half of them are unique,
+// and the other half are drawn from 250 sequences (about 10 copies
+// each).
+func dupReads() (entries []*fastq.FastqEntry) {
+	r := rand.New(rand.NewSource(1))
+	random := func() []byte {
+		seq := make([]byte, 50)
+		for i := range seq {
+			seq[i] = "ACGT"[r.Intn(4)]
+		}
+		return seq
+	}
+	pool := make([][]byte, 250)
+	for i := range pool {
+		pool[i] = random()
+	}
+	for i := 0; i < 5000; i++ {
+		seq := random()
+		if i%2 == 0 {
+			seq = pool[r.Intn(len(pool))]
+		}
+		entries = append(entries, &fastq.FastqEntry{Name: []byte("@read"), Sequence: seq})
+	}
+	return
+}
+
+func TestDuplication(t *testing.T) {
+	entries := dupReads()
+	// Second reads are reverse complements of first reads, so that
+	// both reads and pairs have the same duplication levels
+	var entries2 []*fastq.FastqEntry
+	for _, e := range entries {
+		seq := append([]byte(nil), e.Sequence...)
+		fastq.ReverseComplement(seq)
+		entries2 = append(entries2, &fastq.FastqEntry{Name: e.Name, Sequence: seq})
+	}
+	for _, maxTracked := range []int{DefaultMaxTracked, 1000} {
+		s, err := ComputeStats(io.NewMemoryReader(entries, entries2), false, false, NewDuplication(maxTracked))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Duplication.Pairs == nil {
+			t.Fatal("missing pair duplication levels")
+		}
+		for _, dc := range []*DuplicationCounter{s.Duplication.Reads, s.Duplication.Pairs} {
+			levels, distinct := dc.Levels()
+			// 2500 unique sequences and 250 duplicated ones
+			if distinct < 0.53 || distinct > 0.57 {
+				t.Errorf("max tracked %d: got %.3f distinct sequences, want 0.55", maxTracked, distinct)
+			}
+			if levels[0] < 0.47 || levels[0] > 0.53 {
+				t.Errorf("max tracked %d: got %.3f unique sequences, want 0.5", maxTracked, levels[0])
+			}
+		}
+		over := s.Duplication.Reads.OverRepresented(5)
+		if len(over) != 5 || over[0].Count < over[4].Count || over[0].Count < 10 {
+			t.Errorf("max tracked %d: unexpected over-represented sequences %v", maxTracked, over)
+		}
+	}
+
+	s, err := ComputeStats(io.NewMemoryReader(entries, nil), false, false, NewDuplication(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Duplication.Pairs != nil {
+		t.Errorf("single-end input must not have pair duplication levels")
+	}
+}
@@ -2,129 +2,389 @@ package stats
 
 import (
 	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/fredericlemoine/fastqutils/fastq"
 )
 
+// Encoding is a base quality encoding of FASTQ files: the offset
+// added to quality scores, and the range of quality characters.
+// Built-in encodings are given by the constants below, and other ones
+// can be added with RegisterEncoding.
+type Encoding int
+
 const (
-	SANGER = iota
+	SANGER Encoding = iota
 	SOLEXA
 	ILLUMINA_1_3
 	ILLUMINA_1_5
 	ILLUMINA_1_8
-	UNKOWN
+	UNKNOWN
+	// New encodings are added after UNKNOWN, whose value must not change
+	LONG_READS // PacBio HiFi, Oxford Nanopore: Phred+33, up to Q93
 )
 
-func DetectEncoding(min, max int) int {
-	if min >= 33 && max <= 73 {
-		return SANGER
-	} else if min >= 33 && max <= 74 {
-		return ILLUMINA_1_8
-	} else if min >= 67 && max <= 104 {
-		return ILLUMINA_1_5
-	} else if min >= 64 && max <= 104 {
-		return ILLUMINA_1_3
-	} else if min >= 59 && max <= 104 {
-		return SOLEXA
-	} else {
-		return UNKOWN
-	}
-}
-
-func EncodingToString(encod int) (enc string, err error) {
-	switch encod {
-	case SANGER:
-		enc = "Sanger"
-	case SOLEXA:
-		enc = "Solexa"
-	case ILLUMINA_1_3:
-		enc = "Illumina 1.3"
-	case ILLUMINA_1_5:
-		enc = "Illumina 1.5"
-	case ILLUMINA_1_8:
-		enc = "Illumina 1.8"
-	case UNKOWN:
-		enc = "Unknown"
-	default:
-		err = fmt.Errorf("this encoding Code does not exist : %d", encod)
+// Deprecated: UNKOWN is a misspelling of UNKNOWN, kept for
+// compatibility.
+const UNKOWN = UNKNOWN
+
+// EncodingSpec describes a quality encoding.
+type EncodingSpec struct {
+	Name    string   // Name given on the command line, e.g. illumina1.8
+	Display string   // Name displayed in reports, e.g. Illumina 1.8 (Name if empty)
+	Aliases []string // Other names accepted by ParseEncoding
+	Offset  int      // Value of the quality character of score 0
+	MinQual int      // Lowest quality character
+	MaxQual int      // Highest quality character
+	LogOdds bool     // Scores are Solexa log-odds instead of Phred scores
+}
+
+var (
+	encodingsMu sync.RWMutex
+	// encodings[e]: specification of encoding e
+	encodings = []EncodingSpec{
+		SANGER:       {Name: "sanger", Display: "Sanger", Aliases: []string{"fastq-sanger"}, Offset: 33, MinQual: 33, MaxQual: 73},
+		SOLEXA:       {Name: "solexa", Display: "Solexa", Aliases: []string{"fastq-solexa"}, Offset: 64, MinQual: 59, MaxQual: 104, LogOdds: true},
+		ILLUMINA_1_3: {Name: "illumina1.3", Display: "Illumina 1.3", Aliases: []string{"phred64", "fastq-illumina", "illumina1.3+"}, Offset: 64, MinQual: 64, MaxQual: 104},
+		// 'B' (Q2) is used as a read segment quality control indicator
+		ILLUMINA_1_5: {Name: "illumina1.5", Display: "Illumina 1.5", Aliases: []string{"illumina1.5+"}, Offset: 64, MinQual: 66, MaxQual: 104},
+		// Up to Q42, for recent instruments
+		ILLUMINA_1_8: {Name: "illumina1.8", Display: "Illumina 1.8", Aliases: []string{"phred33", "illumina1.8+", "casava1.8"}, Offset: 33, MinQual: 33, MaxQual: 75},
+		UNKNOWN:      {Name: "unknown", Display: "Unknown", Offset: 0, MinQual: 0, MaxQual: 126},
+		LONG_READS:   {Name: "longreads", Display: "Long reads", Aliases: []string{"long-reads"}, Offset: 33, MinQual: 33, MaxQual: 126},
+	}
+	// encodingNames: encodings by lower case name and alias
+	encodingNames = make(map[string]Encoding)
+)
+
+func init() {
+	for e, spec := range encodings {
+		for _, name := range append([]string{spec.Name}, spec.Aliases...) {
+			encodingNames[name] = Encoding(e)
+		}
 	}
-	return
 }
 
-func EncodingFromString(encod string) (enc int, err error) {
-	switch encod {
-	case "sanger":
-		enc = SANGER
-	case "solexa":
-		enc = SOLEXA
-	case "illumina1.3":
-		enc = ILLUMINA_1_3
-	case "illumina1.5":
-		enc = ILLUMINA_1_5
-	case "illumina1.8":
-		enc = ILLUMINA_1_8
-	case "unknown":
-		enc = UNKOWN
-	default:
-		err = fmt.Errorf("this encoding Code does not exist : %s, possible values are : sanger, solexa, illumina1.3, illumina1.5, illumina1.8", encod)
+// RegisterEncoding adds a custom quality encoding, e.g. for a
+// sequencing platform with a specific range of qualities, and returns
+// it. Its name and aliases are case insensitive, and must not be used
+// by another encoding. Its quality characters must be printable
+// ('!' to '~').
+//
+// Custom encodings are candidates of the detection (see
+// EncodingCandidates) only if no built-in encoding matches the
+// qualities.
+func RegisterEncoding(spec EncodingSpec) (Encoding, error) {
+	spec.Name = strings.ToLower(strings.TrimSpace(spec.Name))
+	if spec.Name == "" {
+		return UNKNOWN, fmt.Errorf("an encoding must have a name")
 	}
-	return
+	if spec.Display == "" {
+		spec.Display = spec.Name
+	}
+	if spec.MinQual < '!' || spec.MaxQual > '~' || spec.MinQual > spec.MaxQual {
+		return UNKNOWN, fmt.Errorf("invalid range of quality characters of encoding %s : %q-%q", spec.Name, spec.MinQual, spec.MaxQual)
+	}
+	aliases := make([]string, len(spec.Aliases))
+	for i, a := range spec.Aliases {
+		aliases[i] = strings.ToLower(strings.TrimSpace(a))
+	}
+	spec.Aliases = aliases
+
+	encodingsMu.Lock()
+	defer encodingsMu.Unlock()
+	for _, name := range append([]string{spec.Name}, spec.Aliases...) {
+		if name == "auto" {
+			return UNKNOWN, fmt.Errorf("encoding name auto is reserved for the detection")
+		}
+		if e, ok := encodingNames[name]; ok {
+			return UNKNOWN, fmt.Errorf("encoding name %s is already used by %s", name, encodings[e].Name)
+		}
+	}
+	e := Encoding(len(encodings))
+	encodings = append(encodings, spec)
+	for _, name := range append([]string{spec.Name}, spec.Aliases...) {
+		encodingNames[name] = e
+	}
+	return e, nil
 }
 
-func EncodingOffset(encod int) (off int, err error) {
-	switch encod {
-	case SANGER:
-		off = 33
-	case SOLEXA:
-		off = 64
-	case ILLUMINA_1_3:
-		off = 64
-	case ILLUMINA_1_5:
-		off = 64
-	case ILLUMINA_1_8:
-		off = 33
-	case UNKOWN:
-		off = 0
-	default:
-		err = fmt.Errorf(" : %d", encod)
+// ParseEncoding returns the encoding of the given name or alias, case
+// insensitive (e.g. illumina1.8, Phred33 or PHRED64).
+func ParseEncoding(name string) (Encoding, error) {
+	encodingsMu.RLock()
+	e, ok := encodingNames[strings.ToLower(strings.TrimSpace(name))]
+	encodingsMu.RUnlock()
+	if !ok {
+		return UNKNOWN, fmt.Errorf("this encoding does not exist : %s, possible values are : %s", name, strings.Join(EncodingNames(), ", "))
 	}
-	return
+	return e, nil
 }
 
-func MinQual(encod int) (minq int, err error) {
-	switch encod {
-	case SANGER:
-		minq = 33
-	case SOLEXA:
-		minq = 59
-	case ILLUMINA_1_3:
-		minq = 64
-	case ILLUMINA_1_5:
-		minq = 67
-	case ILLUMINA_1_8:
-		minq = 33
-	case UNKOWN:
-		minq = 0
-	default:
-		err = fmt.Errorf("this encoding Code does not exist : %d", encod)
+// EncodingNames returns the names of the known encodings, built-in
+// ones first, except UNKNOWN.
+func EncodingNames() (names []string) {
+	encodingsMu.RLock()
+	defer encodingsMu.RUnlock()
+	for e, spec := range encodings {
+		if Encoding(e) != UNKNOWN {
+			names = append(names, spec.Name)
+		}
 	}
 	return
 }
 
-func MaxQual(encod int) (maxq int, err error) {
-	switch encod {
-	case SANGER:
-		maxq = 73
-	case SOLEXA:
-		maxq = 104
-	case ILLUMINA_1_3:
-		maxq = 104
-	case ILLUMINA_1_5:
-		maxq = 104
-	case ILLUMINA_1_8:
-		maxq = 74
-	case UNKOWN:
-		maxq = 126
-	default:
-		err = fmt.Errorf("this encoding Code does not exist : %d", encod)
+// Spec returns the specification of e, and false if e is not a known
+// encoding.
+func (e Encoding) Spec() (EncodingSpec, bool) {
+	encodingsMu.RLock()
+	defer encodingsMu.RUnlock()
+	if e < 0 || int(e) >= len(encodings) {
+		return EncodingSpec{}, false
+	}
+	return encodings[e], true
+}
+
+// String returns the display name of e, e.g. "Illumina 1.8".
+func (e Encoding) String() string {
+	if spec, ok := e.Spec(); ok {
+		return spec.Display
+	}
+	return fmt.Sprintf("Encoding(%d)", int(e))
+}
+
+// Name returns the name of e, as accepted by ParseEncoding, e.g.
+// "illumina1.8".
+func (e Encoding) Name() string {
+	if spec, ok := e.Spec(); ok {
+		return spec.Name
+	}
+	return fmt.Sprintf("encoding(%d)", int(e))
+}
+
+// Offset returns the value of the quality character of score 0 (0 for
+// UNKNOWN).
+func (e Encoding) Offset() int {
+	spec, _ := e.Spec()
+	return spec.Offset
+}
+
+// MinQual returns the lowest quality character of e.
+func (e Encoding) MinQual() int {
+	spec, _ := e.Spec()
+	return spec.MinQual
+}
+
+// MaxQual returns the highest quality character of e.
+func (e Encoding) MaxQual() int {
+	spec, _ := e.Spec()
+	return spec.MaxQual
+}
+
+// LogOdds returns true if scores of e are Solexa log-odds instead of
+// Phred scores.
+func (e Encoding) LogOdds() bool {
+	spec, _ := e.Spec()
+	return spec.LogOdds
+}
+
+// EncodingFlag is a command line flag value (it implements pflag.Value)
+// giving a quality encoding, validated by ParseEncoding when the flag
+// is parsed. If AllowAuto is true, it also accepts "auto", meaning that
+// the encoding must be detected from reads, and sets Auto.
+type EncodingFlag struct {
+	Encoding  Encoding
+	Auto      bool
+	AllowAuto bool
+}
+
+// String implements pflag.Value.
+func (f *EncodingFlag) String() string {
+	if f.Auto {
+		return "auto"
+	}
+	return f.Encoding.Name()
+}
+
+// Set implements pflag.Value.
+func (f *EncodingFlag) Set(value string) error {
+	if f.AllowAuto && strings.EqualFold(strings.TrimSpace(value), "auto") {
+		f.Auto = true
+		return nil
+	}
+	e, err := ParseEncoding(value)
+	if err != nil {
+		return err
+	}
+	if e == UNKNOWN {
+		return fmt.Errorf("the quality encoding must be known")
+	}
+	f.Encoding, f.Auto = e, false
+	return nil
+}
+
+// Type implements pflag.Value.
+func (f *EncodingFlag) Type() string {
+	return "encoding"
+}
+
+// DefaultEncodingReads is the default number of reads examined by
+// EncodingDetector.
+const DefaultEncodingReads = 10000
+
+// encodingPreference lists the built-in encodings, in the order in
+// which they are chosen when several ones match the qualities of
+// reads, and share the same offset: the most common, then the
+// narrowest ranges.
+var encodingPreference = []Encoding{ILLUMINA_1_8, SANGER, ILLUMINA_1_5, ILLUMINA_1_3, SOLEXA}
+
+// EncodingCandidates returns the encodings whose range of quality
+// characters contains [min,max], in order of preference. Since the
+// range of LONG_READS contains the ranges of all the other built-in
+// encodings, it is a candidate only if no other built-in encoding
+// matches, as well as the encodings added with RegisterEncoding.
+func EncodingCandidates(min, max int) (candidates []Encoding) {
+	encodingsMu.RLock()
+	defer encodingsMu.RUnlock()
+	matches := func(e Encoding) bool {
+		return min >= encodings[e].MinQual && max <= encodings[e].MaxQual
+	}
+	for _, e := range encodingPreference {
+		if matches(e) {
+			candidates = append(candidates, e)
+		}
+	}
+	if len(candidates) > 0 {
+		return
+	}
+	if matches(LONG_READS) {
+		candidates = append(candidates, LONG_READS)
+	}
+	for e := LONG_READS + 1; int(e) < len(encodings); e++ {
+		if matches(e) {
+			candidates = append(candidates, e)
+		}
 	}
 	return
 }
+
+// DetectEncoding returns the encoding of quality characters ranging
+// from min to max: the preferred one among the candidates (see
+// EncodingCandidates). It returns UNKNOWN if no encoding matches, or
+// if the candidates do not share the same offset, e.g. for qualities
+// between '@' and 'I', that are either high Phred+33 qualities or low
+// Phred+64 qualities.
+func DetectEncoding(min, max int) Encoding {
+	enc, _ := detectEncoding(min, max)
+	return enc
+}
+
+// detectEncoding is DetectEncoding, also returning an error
+// explaining why the encoding is UNKNOWN.
+func detectEncoding(min, max int) (enc Encoding, err error) {
+	candidates := EncodingCandidates(min, max)
+	if len(candidates) == 0 {
+		return UNKNOWN, fmt.Errorf("no known quality encoding matches quality characters from %q to %q", min, max)
+	}
+	off := candidates[0].Offset()
+	for _, c := range candidates[1:] {
+		if c.Offset() != off {
+			names := make([]string, len(candidates))
+			for i, c := range candidates {
+				names[i] = c.String()
+			}
+			return UNKNOWN, fmt.Errorf("ambiguous quality encoding, quality characters from %q to %q match : %s", min, max, strings.Join(names, ", "))
+		}
+	}
+	return candidates[0], nil
+}
+
+// EncodingDetector detects the quality encoding of reads, from the
+// range of the quality characters of the first MaxReads reads (all
+// reads if MaxReads is 0). It implements Module.
+type EncodingDetector struct {
+	MaxReads int // Maximum number of reads examined
+	NReads   int // Number of examined reads
+	min, max int // Range of quality characters
+}
+
+// NewEncodingDetector returns an EncodingDetector examining at most
+// maxReads reads.
+func NewEncodingDetector(maxReads int) *EncodingDetector {
+	return &EncodingDetector{MaxReads: maxReads, min: 1000, max: -1}
+}
+
+// Add implements Module. Reads without qualities are not examined.
+func (d *EncodingDetector) Add(entry *fastq.FastqEntry) {
+	if d.Full() || len(entry.Quality) == 0 {
+		return
+	}
+	for _, q := range entry.Quality {
+		d.min = min(d.min, int(q))
+		d.max = max(d.max, int(q))
+	}
+	d.NReads++
+}
+
+// Full returns true if MaxReads reads have been examined.
+func (d *EncodingDetector) Full() bool {
+	return d.MaxReads > 0 && d.NReads >= d.MaxReads
+}
+
+// Detect returns the encoding of examined reads (see DetectEncoding).
+// Instead of guessing, it returns UNKNOWN and an error if no encoding
+// matches, if the encoding is ambiguous, or if no read was examined.
+func (d *EncodingDetector) Detect() (enc Encoding, err error) {
+	if d.NReads == 0 {
+		return UNKNOWN, fmt.Errorf("the quality encoding cannot be detected without qualities")
+	}
+	return detectEncoding(d.min, d.max)
+}
+
+// EncodingToString returns the display name of encod.
+//
+// Deprecated: use Encoding.String.
+func EncodingToString(encod Encoding) (enc string, err error) {
+	if _, ok := encod.Spec(); !ok {
+		return "", fmt.Errorf("this encoding Code does not exist : %d", encod)
+	}
+	return encod.String(), nil
+}
+
+// EncodingFromString returns the encoding of the given name.
+//
+// Deprecated: use ParseEncoding.
+func EncodingFromString(encod string) (Encoding, error) {
+	return ParseEncoding(encod)
+}
+
+// EncodingOffset returns the offset of encod.
+//
+// Deprecated: use Encoding.Offset.
+func EncodingOffset(encod Encoding) (off int, err error) {
+	if _, ok := encod.Spec(); !ok {
+		return 0, fmt.Errorf("this encoding Code does not exist : %d", encod)
+	}
+	return encod.Offset(), nil
+}
+
+// MinQual returns the lowest quality character of encod.
+//
+// Deprecated: use Encoding.MinQual.
+func MinQual(encod Encoding) (minq int, err error) {
+	if _, ok := encod.Spec(); !ok {
+		return 0, fmt.Errorf("this encoding Code does not exist : %d", encod)
+	}
+	return encod.MinQual(), nil
+}
+
+// MaxQual returns the highest quality character of encod.
+//
+// Deprecated: use Encoding.MaxQual.
+func MaxQual(encod Encoding) (maxq int, err error) {
+	if _, ok := encod.Spec(); !ok {
+		return 0, fmt.Errorf("this encoding Code does not exist : %d", encod)
+	}
+	return encod.MaxQual(), nil
+}
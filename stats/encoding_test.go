@@ -0,0 +1,114 @@
+package stats
+
+import (
+	"testing"
+
+	"github.com/fredericlemoine/fastqutils/fastq"
+)
+
+func TestDetectEncoding(t *testing.T) {
+	for _, test := range []struct {
+		min, max int
+		want     Encoding
+		err      bool
+	}{
+		{'!', 'I', ILLUMINA_1_8, false},
+		{'#', 'J', ILLUMINA_1_8, false},
+		{'#', 'K', ILLUMINA_1_8, false}, // Extended Illumina 1.8+ range
+		{'!', '~', LONG_READS, false},   // PacBio HiFi
+		{'B', 'h', ILLUMINA_1_5, false},
+		{'@', 'h', ILLUMINA_1_3, false},
+		{';', 'h', SOLEXA, false},
+		{'@', 'I', UNKNOWN, true}, // High Phred+33 or low Phred+64 qualities
+		{' ', 'I', UNKNOWN, true},
+	} {
+		d := NewEncodingDetector(2)
+		d.Add(&fastq.FastqEntry{Quality: []byte{byte(test.min)}})
+		d.Add(&fastq.FastqEntry{Quality: []byte{byte(test.max)}})
+		// Ignored: only 2 reads are examined
+		d.Add(&fastq.FastqEntry{Quality: []byte{0}})
+		enc, err := d.Detect()
+		if enc != test.want || (err != nil) != test.err {
+			t.Errorf("qualities %q to %q: got %d (%v), want %d", test.min, test.max, enc, err, test.want)
+		}
+		if DetectEncoding(test.min, test.max) != test.want {
+			t.Errorf("qualities %q to %q: DetectEncoding differs from EncodingDetector", test.min, test.max)
+		}
+	}
+
+	if _, err := NewEncodingDetector(0).Detect(); err == nil {
+		t.Errorf("detection without qualities must fail")
+	}
+}
+
+func TestParseEncoding(t *testing.T) {
+	for name, want := range map[string]Encoding{
+		"illumina1.8":  ILLUMINA_1_8,
+		"Illumina1.8":  ILLUMINA_1_8,
+		"PHRED33":      ILLUMINA_1_8,
+		"phred64":      ILLUMINA_1_3,
+		"fastq-solexa": SOLEXA,
+		"longreads":    LONG_READS,
+		"unknown":      UNKNOWN,
+	} {
+		if enc, err := ParseEncoding(name); err != nil || enc != want {
+			t.Errorf("%s: got %v (%v), want %v", name, enc, err, want)
+		}
+	}
+	// Values of the original encodings are stable
+	if UNKOWN != 5 || ILLUMINA_1_8 != 4 {
+		t.Errorf("encoding values have changed: ILLUMINA_1_8=%d, UNKOWN=%d", ILLUMINA_1_8, UNKOWN)
+	}
+	if _, err := ParseEncoding("phred42"); err == nil {
+		t.Errorf("phred42 must not be parsed")
+	}
+}
+
+func TestRegisterEncoding(t *testing.T) {
+	nanopore, err := RegisterEncoding(EncodingSpec{Name: "Nanopore-test", Display: "Nanopore", Aliases: []string{"ont-test"}, Offset: 33, MinQual: '!', MaxQual: 'Z'})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if enc, err := ParseEncoding("ONT-test"); err != nil || enc != nanopore {
+		t.Errorf("ONT-test: got %v (%v), want %v", enc, err, nanopore)
+	}
+	if nanopore.String() != "Nanopore" || nanopore.Name() != "nanopore-test" || nanopore.Offset() != 33 || nanopore.MaxQual() != 'Z' {
+		t.Errorf("wrong specification of the registered encoding: %+v", nanopore)
+	}
+	// Built-in encodings are preferred
+	if enc := DetectEncoding('!', 'I'); enc != ILLUMINA_1_8 {
+		t.Errorf("qualities '!' to 'I': got %v, want %v", enc, ILLUMINA_1_8)
+	}
+
+	for _, spec := range []EncodingSpec{
+		{Name: "phred33", Offset: 33, MinQual: '!', MaxQual: 'J'},  // Used name
+		{Name: "auto", Offset: 33, MinQual: '!', MaxQual: 'J'},     // Reserved name
+		{Name: "", Offset: 33, MinQual: '!', MaxQual: 'J'},         // No name
+		{Name: "binary-test", Offset: 0, MinQual: 0, MaxQual: 'J'}, // Not printable
+	} {
+		if _, err := RegisterEncoding(spec); err == nil {
+			t.Errorf("encoding %+v must not be registered", spec)
+		}
+	}
+}
+
+func TestEncodingFlag(t *testing.T) {
+	f := EncodingFlag{Encoding: ILLUMINA_1_8}
+	if err := f.Set("auto"); err == nil {
+		t.Errorf("auto must not be accepted without AllowAuto")
+	}
+	if err := f.Set("unknown"); err == nil {
+		t.Errorf("unknown must not be accepted")
+	}
+	if err := f.Set("Phred64"); err != nil || f.Encoding != ILLUMINA_1_3 || f.String() != "illumina1.3" {
+		t.Errorf("Phred64: got %v (%v)", f.String(), err)
+	}
+
+	f.AllowAuto = true
+	if err := f.Set("AUTO"); err != nil || !f.Auto || f.String() != "auto" {
+		t.Errorf("AUTO: got %v (%v)", f.String(), err)
+	}
+	if err := f.Set("sanger"); err != nil || f.Auto || f.Encoding != SANGER {
+		t.Errorf("sanger: got %v (%v)", f.String(), err)
+	}
+}
@@ -0,0 +1,210 @@
+package stats
+
+import (
+	"math"
+
+	"github.com/fredericlemoine/fastqutils/fastq"
+	"github.com/fredericlemoine/fastqutils/hist"
+)
+
+// MaxGCDeviation is the fraction of reads outside the theoretical GC
+// distribution above which the GC content is considered suspicious.
+const MaxGCDeviation = 0.15
+
+const (
+	gcSmoothing = 2    // Half-width of the moving average used to find peaks
+	gcMinPeak   = 0.1  // Minimum height of a peak, relative to the highest one
+	gcMaxValley = 0.75 // Maximum depth of the valley separating two peaks, relative to the lowest one
+)
+
+// GCContent is a Module computing the distribution of the GC content
+// of reads (in percent, rounded to the nearest integer), and comparing
+// it to a normal distribution fitted to the data, as FastQC per
+// sequence GC content.
+//
+// Reads of a single genome give a roughly normal distribution. A
+// distribution with several peaks, or deviating from the fitted normal
+// distribution, suggests a contamination (or a metagenomic sample).
+type GCContent struct {
+	NReads int64      // Number of reads with at least one A, C, G or T
+	counts [101]int64 // counts[p]: number of reads with p% GC
+}
+
+// NewGCContent returns an empty GCContent.
+func NewGCContent() *GCContent {
+	return &GCContent{}
+}
+
+// Histogram returns the histogram of the GC content of reads, built
+// from the number of reads of every GC percentage.
+func (gc *GCContent) Histogram() *hist.IntHistogram {
+	h := hist.NewIntHistogram(20)
+	for p, c := range gc.counts {
+		h.AddPoints(p, int(c))
+	}
+	return h
+}
+
+// Add implements Module. N (and other IUPAC codes) are not counted in
+// the length of the read.
+func (gc *GCContent) Add(entry *fastq.FastqEntry) {
+	var n, ngc int
+	for _, b := range entry.Sequence {
+		switch b {
+		case 'G', 'C', 'g', 'c':
+			ngc++
+			n++
+		case 'A', 'T', 'a', 't':
+			n++
+		}
+	}
+	if n == 0 {
+		return
+	}
+	p := int(math.Round(100 * float64(ngc) / float64(n)))
+	gc.counts[p]++
+	gc.NReads++
+}
+
+// Distribution returns, for every GC percentage from 0 to 100, the
+// fraction of reads having this GC content.
+func (gc *GCContent) Distribution() []float64 {
+	dist := make([]float64, len(gc.counts))
+	if gc.NReads == 0 {
+		return dist
+	}
+	for p, c := range gc.counts {
+		dist[p] = float64(c) / float64(gc.NReads)
+	}
+	return dist
+}
+
+// Mean returns the mean GC percentage of reads.
+func (gc *GCContent) Mean() float64 {
+	sum := 0.0
+	for p, c := range gc.counts {
+		sum += float64(p) * float64(c)
+	}
+	return sum / float64(gc.NReads)
+}
+
+// Theoretical returns the normal distribution fitted to the GC
+// content of reads: it is centered on the mode of the distribution
+// (averaged over adjacent GC percentages with the same count), and its
+// standard deviation is computed around this mode, so that a secondary
+// population of reads widens it without shifting it. theo gives the
+// expected fraction of reads for every GC percentage from 0 to 100.
+func (gc *GCContent) Theoretical() (mode, sd float64, theo []float64) {
+	theo = make([]float64, len(gc.counts))
+	if gc.NReads == 0 {
+		return math.NaN(), math.NaN(), theo
+	}
+	best := 0
+	for p, c := range gc.counts {
+		if c > gc.counts[best] {
+			best = p
+		}
+	}
+	last := best
+	for last+1 < len(gc.counts) && gc.counts[last+1] == gc.counts[best] {
+		last++
+	}
+	mode = float64(best+last) / 2
+
+	for p, c := range gc.counts {
+		sd += float64(c) * (float64(p) - mode) * (float64(p) - mode)
+	}
+	sd = math.Sqrt(sd / float64(gc.NReads))
+
+	// The density is integrated over each percentage, then normalized
+	// since the distribution is truncated to [0,100]
+	sum := 0.0
+	for p := range theo {
+		if sd == 0 {
+			if float64(p) == mode {
+				theo[p] = 1
+			}
+		} else {
+			theo[p] = normalCDF(float64(p)+0.5, mode, sd) - normalCDF(float64(p)-0.5, mode, sd)
+		}
+		sum += theo[p]
+	}
+	if sum > 0 {
+		for p := range theo {
+			theo[p] /= sum
+		}
+	}
+	return
+}
+
+// normalCDF is the cumulative distribution function of the normal
+// distribution.
+func normalCDF(x, mean, sd float64) float64 {
+	return 0.5 * math.Erfc(-(x-mean)/(sd*math.Sqrt2))
+}
+
+// Deviation returns the fraction of reads outside the theoretical
+// distribution (see Theoretical): half of the sum of the absolute
+// differences between observed and theoretical fractions, between 0
+// and 1.
+func (gc *GCContent) Deviation() float64 {
+	_, _, theo := gc.Theoretical()
+	dev := 0.0
+	for p, f := range gc.Distribution() {
+		dev += math.Abs(f - theo[p])
+	}
+	return dev / 2
+}
+
+// Peaks returns the GC percentages of the peaks of the distribution,
+// smoothed by a moving average. Peaks lower than gcMinPeak times the
+// highest one are ignored, as well as peaks that are not separated
+// from a higher peak by a deep enough valley.
+func (gc *GCContent) Peaks() (peaks []int) {
+	dist := gc.Distribution()
+	smooth := make([]float64, len(dist))
+	highest := 0.0
+	for p := range dist {
+		n := 0
+		for i := max(0, p-gcSmoothing); i <= min(len(dist)-1, p+gcSmoothing); i++ {
+			smooth[p] += dist[i]
+			n++
+		}
+		smooth[p] /= float64(n)
+		highest = math.Max(highest, smooth[p])
+	}
+	if highest == 0 {
+		return nil
+	}
+
+	for p := range smooth {
+		left := p == 0 || smooth[p] > smooth[p-1]
+		right := p == len(smooth)-1 || smooth[p] >= smooth[p+1]
+		if !left || !right || smooth[p] < gcMinPeak*highest {
+			continue
+		}
+		if n := len(peaks); n > 0 {
+			prev := peaks[n-1]
+			valley := smooth[prev]
+			for i := prev; i <= p; i++ {
+				valley = math.Min(valley, smooth[i])
+			}
+			if valley > gcMaxValley*math.Min(smooth[prev], smooth[p]) {
+				// Same peak: the highest is kept
+				if smooth[p] > smooth[prev] {
+					peaks[n-1] = p
+				}
+				continue
+			}
+		}
+		peaks = append(peaks, p)
+	}
+	return
+}
+
+// Suspicious returns true if the GC distribution has several peaks,
+// or deviates from the theoretical distribution by more than
+// MaxGCDeviation, which suggests a contamination.
+func (gc *GCContent) Suspicious() bool {
+	return len(gc.Peaks()) > 1 || gc.Deviation() > MaxGCDeviation
+}
@@ -0,0 +1,60 @@
+package stats
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/fredericlemoine/fastqutils/fastq"
+)
+
+// gcReads returns n random reads of length 100, whose bases are G or
+// C with probability gc.
+func gcReads(r *rand.Rand, gc float64, n int) (entries []*fastq.FastqEntry) {
+	for i := 0; i < n; i++ {
+		seq := make([]byte, 100)
+		for j := range seq {
+			if r.Float64() < gc {
+				seq[j] = "GC"[r.Intn(2)]
+			} else {
+				seq[j] = "AT"[r.Intn(2)]
+			}
+		}
+		entries = append(entries, &fastq.FastqEntry{Name: []byte("@read"), Sequence: seq})
+	}
+	return
+}
+
+func TestGCContent(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	gc := NewGCContent()
+	for _, e := range gcReads(r, 0.4, 5000) {
+		gc.Add(e)
+	}
+	mode, sd, _ := gc.Theoretical()
+	if m := gc.Mean(); m < 39 || m > 41 || mode < 37 || mode > 43 {
+		t.Errorf("got mean %.2f and mode %.1f, want about 40", m, mode)
+	}
+	// Binomial standard deviation: sqrt(100*0.4*0.6)
+	if sd < 4 || sd > 6 {
+		t.Errorf("got standard deviation %.2f, want about 4.9", sd)
+	}
+	if peaks := gc.Peaks(); len(peaks) != 1 || gc.Suspicious() {
+		t.Errorf("got peaks %v, deviation %.2f, want a single population", peaks, gc.Deviation())
+	}
+
+	// Contamination by a GC rich genome
+	for _, e := range gcReads(r, 0.65, 2000) {
+		gc.Add(e)
+	}
+	if peaks := gc.Peaks(); len(peaks) != 2 || !gc.Suspicious() {
+		t.Errorf("got peaks %v, deviation %.2f, want two populations", peaks, gc.Deviation())
+	}
+
+	// Reads without A, C, G or T are ignored
+	gc = NewGCContent()
+	gc.Add(&fastq.FastqEntry{Name: []byte("@read"), Sequence: []byte("NNNN")})
+	if gc.NReads != 0 || gc.Peaks() != nil {
+		t.Errorf("got %d reads, want 0", gc.NReads)
+	}
+}
@@ -0,0 +1,147 @@
+package stats
+
+import (
+	"errors"
+)
+
+// ErrNoCounts is returned by Merge for Stats that were not computed
+// by ComputeStats or Merge.
+var ErrNoCounts = errors.New("statistics cannot be merged: raw counts are missing")
+
+// Merge returns the statistics of the union of the inputs of s1 and s2,
+// as if they were computed by a single call to ComputeStats. Optional
+// statistics (histograms, per-position statistics, adapter and GC
+// content, duplication levels) are kept only if they are computed in
+// both. s1 and s2 are not modified.
+func Merge(s1, s2 Stats) (s Stats, err error) {
+	if s1.counts == nil || s2.counts == nil {
+		return s, ErrNoCounts
+	}
+	return s1.counts.merge(s2.counts).stats(), nil
+}
+
+// merge returns new counts with the counts of c1 and c2.
+func (c1 *counts) merge(c2 *counts) *counts {
+	c := &counts{
+		nbrecords: c1.nbrecords + c2.nbrecords,
+		paired:    c1.paired && c2.paired,
+		total:     c1.total + c2.total,
+		sumQual:   c1.sumQual + c2.sumQual,
+		totalQual: c1.totalQual + c2.totalQual,
+		minqual:   min(c1.minqual, c2.minqual),
+		maxqual:   max(c1.maxqual, c2.maxqual),
+	}
+	for i := range c.nt {
+		c.nt[i] = c1.nt[i] + c2.nt[i]
+	}
+	if c1.qualHistogram != nil && c2.qualHistogram != nil {
+		c.qualHistogram = c1.qualHistogram.Merge(c2.qualHistogram)
+		c.lenHistogram = c1.lenHistogram.Merge(c2.lenHistogram)
+	}
+	if c1.positions1 != nil && c2.positions1 != nil {
+		c.positions1 = c1.positions1.merge(c2.positions1)
+		c.positions2 = c1.positions2.merge(c2.positions2)
+	}
+	if c1.adapters != nil && c2.adapters != nil {
+		c.adapters = c1.adapters.merge(c2.adapters)
+	}
+	if c1.gc != nil && c2.gc != nil {
+		c.gc = c1.gc.merge(c2.gc)
+	}
+	if c1.duplication != nil && c2.duplication != nil {
+		c.duplication = c1.duplication.merge(c2.duplication)
+	}
+	if c1.mate1 != nil && c2.mate1 != nil {
+		c.mate1 = c1.mate1.merge(c2.mate1)
+		c.mate2 = c1.mate2.merge(c2.mate2)
+	}
+	return c
+}
+
+// merge returns a new positionCounter with the counts of pc and o.
+func (pc *positionCounter) merge(o *positionCounter) *positionCounter {
+	m := &positionCounter{}
+	for _, c := range []*positionCounter{pc, o} {
+		for len(m.nt) < len(c.nt) {
+			m.nt = append(m.nt, [5]int64{})
+			m.qual = append(m.qual, [128]int64{})
+		}
+		for p := range c.nt {
+			for i, n := range c.nt[p] {
+				m.nt[p][i] += n
+			}
+			for q, n := range c.qual[p] {
+				m.qual[p][q] += n
+			}
+		}
+	}
+	return m
+}
+
+// merge returns a new AdapterContent with the counts of ac and o,
+// which must search the same adapters.
+func (ac *AdapterContent) merge(o *AdapterContent) *AdapterContent {
+	ac.initCounts()
+	o.initCounts()
+	m := &AdapterContent{
+		Adapters: ac.Adapters,
+		NReads:   ac.NReads + o.NReads,
+		Length:   max(ac.Length, o.Length),
+		starts:   make([][]int64, len(ac.Adapters)),
+		kmers:    make(map[uint32]int, len(ac.kmers)),
+		sampled:  ac.sampled + o.sampled,
+	}
+	for _, c := range []*AdapterContent{ac, o} {
+		for i := range c.starts {
+			for len(m.starts[i]) < len(c.starts[i]) {
+				m.starts[i] = append(m.starts[i], 0)
+			}
+			for p, n := range c.starts[i] {
+				m.starts[i][p] += n
+			}
+		}
+		for code, n := range c.kmers {
+			m.kmers[code] += n
+		}
+	}
+	return m
+}
+
+// merge returns a new GCContent with the counts of gc and o.
+func (gc *GCContent) merge(o *GCContent) *GCContent {
+	m := &GCContent{NReads: gc.NReads + o.NReads}
+	for p := range m.counts {
+		m.counts[p] = gc.counts[p] + o.counts[p]
+	}
+	return m
+}
+
+// merge returns a new Duplication with the counts of d and o.
+func (d *Duplication) merge(o *Duplication) *Duplication {
+	m := &Duplication{Reads: d.Reads.merge(o.Reads)}
+	if d.Pairs != nil && o.Pairs != nil {
+		m.Pairs = d.Pairs.merge(o.Pairs)
+	}
+	return m
+}
+
+// merge returns a new DuplicationCounter with the counts of dc and o.
+// Sequences tracked in any of them are kept, so that the merged counter
+// may track more than MaxTracked sequences, and sequences that were
+// tracked in only one of them are undercounted: duplication levels
+// of merged counters are estimates, unless no sequence was left
+// untracked.
+func (dc *DuplicationCounter) merge(o *DuplicationCounter) *DuplicationCounter {
+	m := &DuplicationCounter{
+		MaxTracked: max(dc.MaxTracked, o.MaxTracked),
+		Total:      dc.Total + o.Total,
+		exact:      dc.exact + o.exact,
+		counts:     make(map[string]int64, len(dc.counts)+len(o.counts)),
+	}
+	for _, c := range []*DuplicationCounter{dc, o} {
+		for seq, n := range c.counts {
+			m.counts[seq] += n
+		}
+	}
+	return m
+}
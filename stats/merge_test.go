@@ -0,0 +1,59 @@
+package stats
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/fredericlemoine/fastqutils/fastq"
+	"github.com/fredericlemoine/fastqutils/io"
+)
+
+func TestMerge(t *testing.T) {
+	var entries1, entries2 []*fastq.FastqEntry
+	for i := 0; i < 200; i++ {
+		entries1 = append(entries1, fastq.GenFastQEntry(50+i%20, i, 35, 74))
+		entries2 = append(entries2, fastq.GenFastQEntry(60, i, 40, 70))
+	}
+	compute := func(e1, e2 []*fastq.FastqEntry) Stats {
+		s, err := ComputeStats(io.NewMemoryReader(e1, e2), true, true, NewAdapterContent(), NewGCContent())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	all := compute(entries1, entries2)
+	merged, err := Merge(compute(entries1[:50], entries2[:50]), compute(entries1[50:], entries2[50:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if merged.NSeq != all.NSeq || !merged.Paired || merged.MinQual != all.MinQual || merged.MaxQual != all.MaxQual || merged.Encoding != all.Encoding {
+		t.Errorf("merged summary differs: got %+v, want %+v", merged, all)
+	}
+	for i := range all.TotalNt {
+		if d := merged.TotalNt[i] - all.TotalNt[i]; d > 1e-9 || d < -1e-9 {
+			t.Errorf("nucleotide %d: got %f, want %f", i, merged.TotalNt[i], all.TotalNt[i])
+		}
+	}
+	if d := merged.MeanQual - all.MeanQual; d > 1e-9 || d < -1e-9 {
+		t.Errorf("mean quality: got %f, want %f", merged.MeanQual, all.MeanQual)
+	}
+	if !reflect.DeepEqual(merged.PerPosition1, all.PerPosition1) || !reflect.DeepEqual(merged.PerPosition2, all.PerPosition2) {
+		t.Errorf("merged per-position statistics differ")
+	}
+	if !reflect.DeepEqual(merged.Adapters.Total(), all.Adapters.Total()) || merged.Adapters.NReads != all.Adapters.NReads {
+		t.Errorf("merged adapter content differs")
+	}
+	if !reflect.DeepEqual(merged.GC.Distribution(), all.GC.Distribution()) {
+		t.Errorf("merged GC content differs")
+	}
+	b1, c1 := merged.QualHistogram.Bins()
+	b2, c2 := all.QualHistogram.Bins()
+	if !reflect.DeepEqual(b1, b2) || !reflect.DeepEqual(c1, c2) {
+		t.Errorf("merged quality histogram differs")
+	}
+
+	if _, err = Merge(Stats{}, all); err != ErrNoCounts {
+		t.Errorf("got %v, want %v", err, ErrNoCounts)
+	}
+}
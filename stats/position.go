@@ -0,0 +1,95 @@
+package stats
+
+import (
+	"math"
+
+	"github.com/fredericlemoine/fastqutils/fastq"
+)
+
+// PositionStats are statistics computed for every position (cycle)
+// of reads. Position 0 is the first base of reads.
+type PositionStats struct {
+	NReads   []int64      // Number of reads covering each position
+	NtFreq   [][5]float64 // Fraction of A, C, G, T and N at each position
+	MeanQual []float64    // Mean base quality (NaN without qualities)
+	Q1       []int        // First quartile of base quality
+	Median   []int        // Median base quality
+	Q3       []int        // Third quartile of base quality
+}
+
+// positionCounter accumulates the counts needed to compute a
+// PositionStats.
+type positionCounter struct {
+	nt   [][5]int64   // nt[p][i]: count of nucleotide i at position p
+	qual [][128]int64 // qual[p][q]: count of quality character q at position p
+}
+
+func (pc *positionCounter) add(entry *fastq.FastqEntry) {
+	for len(pc.nt) < len(entry.Sequence) {
+		pc.nt = append(pc.nt, [5]int64{})
+		pc.qual = append(pc.qual, [128]int64{})
+	}
+	for p, b := range entry.Sequence {
+		nt, err := fastq.Index(b)
+		if err != nil {
+			// Other IUPAC codes are counted as N
+			nt = 4
+		}
+		pc.nt[p][nt]++
+	}
+	for p, q := range entry.Quality {
+		if p < len(pc.qual) && q < 128 {
+			pc.qual[p][q]++
+		}
+	}
+}
+
+// stats computes the PositionStats, with qualities decoded with the
+// given offset.
+func (pc *positionCounter) stats(offset int) *PositionStats {
+	n := len(pc.nt)
+	ps := &PositionStats{
+		NReads:   make([]int64, n),
+		NtFreq:   make([][5]float64, n),
+		MeanQual: make([]float64, n),
+		Q1:       make([]int, n),
+		Median:   make([]int, n),
+		Q3:       make([]int, n),
+	}
+	for p := 0; p < n; p++ {
+		for _, c := range pc.nt[p] {
+			ps.NReads[p] += c
+		}
+		for i, c := range pc.nt[p] {
+			ps.NtFreq[p][i] = float64(c) / float64(ps.NReads[p])
+		}
+
+		var nqual, sum int64
+		for q, c := range pc.qual[p] {
+			nqual += c
+			sum += int64(q) * c
+		}
+		if nqual == 0 {
+			ps.MeanQual[p] = math.NaN()
+			continue
+		}
+		ps.MeanQual[p] = float64(sum)/float64(nqual) - float64(offset)
+		ps.Q1[p] = quantile(pc.qual[p][:], nqual, 0.25) - offset
+		ps.Median[p] = quantile(pc.qual[p][:], nqual, 0.5) - offset
+		ps.Q3[p] = quantile(pc.qual[p][:], nqual, 0.75) - offset
+	}
+	return ps
+}
+
+// quantile returns the smallest value v such that at least a fraction
+// f of the total values of the histogram are <= v.
+func quantile(histo []int64, total int64, f float64) int {
+	var cumul int64
+	for v, c := range histo {
+		cumul += c
+		if float64(cumul) >= f*float64(total) && c > 0 {
+			return v
+		}
+	}
+	return len(histo) - 1
+}
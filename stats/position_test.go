@@ -0,0 +1,52 @@
+package stats
+
+import (
+	"math"
+	"testing"
+
+	"github.com/fredericlemoine/fastqutils/fastq"
+	"github.com/fredericlemoine/fastqutils/io"
+)
+
+func TestPerPosition(t *testing.T) {
+	// Phred+33 qualities
+	entries1 := []*fastq.FastqEntry{
+		{Name: []byte("@r1/1"), Sequence: []byte("AC"), Quality: []byte("+5")},
+		{Name: []byte("@r2/1"), Sequence: []byte("AG"), Quality: []byte("5?")},
+		{Name: []byte("@r3/1"), Sequence: []byte("CN"), Quality: []byte("?I")},
+		{Name: []byte("@r4/1"), Sequence: []byte("AY"), Quality: []byte("I#")},
+	}
+	entries2 := []*fastq.FastqEntry{
+		{Name: []byte("@r1/2"), Sequence: []byte("TTT"), Quality: []byte("III")},
+		{Name: []byte("@r2/2"), Sequence: []byte("T"), Quality: []byte("I")},
+		{Name: []byte("@r3/2"), Sequence: []byte("T"), Quality: []byte("I")},
+		{Name: []byte("@r4/2"), Sequence: []byte("T"), Quality: []byte("I")},
+	}
+	s, err := ComputeStats(io.NewMemoryReader(entries1, entries2), false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p1, p2 := s.PerPosition1, s.PerPosition2
+	if p1 == nil || p2 == nil {
+		t.Fatal("per-position statistics not computed")
+	}
+	if len(p1.NReads) != 2 || p1.NReads[0] != 4 || p1.NtFreq[0] != [5]float64{0.75, 0.25, 0, 0, 0} || p1.NtFreq[1] != [5]float64{0, 0.25, 0.25, 0, 0.5} {
+		t.Errorf("unexpected first read composition: %v %v", p1.NReads, p1.NtFreq)
+	}
+	// Qualities at position 1: 10, 20, 30, 40
+	if p1.MeanQual[0] != 25 || p1.Q1[0] != 10 || p1.Median[0] != 20 || p1.Q3[0] != 30 {
+		t.Errorf("unexpected first position qualities: mean %v, quartiles %d %d %d", p1.MeanQual[0], p1.Q1[0], p1.Median[0], p1.Q3[0])
+	}
+	if len(p2.NReads) != 3 || p2.NReads[2] != 1 || p2.NtFreq[2][3] != 1 || p2.Median[2] != 40 {
+		t.Errorf("unexpected second read statistics: %v %v %v", p2.NReads, p2.NtFreq, p2.Median)
+	}
+
+	// Without qualities
+	s, err = ComputeStats(io.NewMemoryReader([]*fastq.FastqEntry{{Name: []byte("@r"), Sequence: []byte("A")}}, nil), false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !math.IsNaN(s.PerPosition1.MeanQual[0]) || s.PerPosition2 != nil {
+		t.Errorf("unexpected statistics for single-end fasta input: %v", s.PerPosition1)
+	}
+}
@@ -0,0 +1,770 @@
+package stats
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	goio "io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/fredericlemoine/fastqutils/fastq"
+	"github.com/fredericlemoine/fastqutils/hist"
+)
+
+// Output formats of statistics
+const (
+	TEXT    = iota // Human readable key/value lines and ASCII histograms
+	JSON           // JSON document, see WriteJSON
+	TSV            // Long tab separated table, see WriteTSV
+	MULTIQC        // MultiQC custom content, see WriteMultiQC
+)
+
+// ReportFormatFromString returns the output format corresponding to
+// the given name (text, json, tsv or multiqc).
+func ReportFormatFromString(format string) (f int, err error) {
+	switch format {
+	case "text":
+		f = TEXT
+	case "json":
+		f = JSON
+	case "tsv":
+		f = TSV
+	case "multiqc":
+		f = MULTIQC
+	default:
+		err = fmt.Errorf("this output format does not exist : %s, possible values are : text, json, tsv, multiqc", format)
+	}
+	return
+}
+
+// WriteReport writes s to w in the given format. sample is the name
+// of the sample, used by the MULTIQC format only.
+func WriteReport(w goio.Writer, s Stats, format int, sample string) error {
+	switch format {
+	case TEXT:
+		return WriteText(w, s)
+	case JSON:
+		return WriteJSON(w, s)
+	case TSV:
+		return WriteTSV(w, s)
+	case MULTIQC:
+		return WriteMultiQC(w, s, sample)
+	}
+	return fmt.Errorf("unknown output format code : %d", format)
+}
+
+// jsonFloat is a float64 encoded as null in JSON when it is NaN (e.g.
+// qualities of FASTA input).
+type jsonFloat float64
+
+func (f jsonFloat) MarshalJSON() ([]byte, error) {
+	if math.IsNaN(float64(f)) || math.IsInf(float64(f), 0) {
+		return []byte("null"), nil
+	}
+	return strconv.AppendFloat(nil, float64(f), 'g', -1, 64), nil
+}
+
+// jsonQual returns a pointer to the quality score q of s, or nil if s
+// has no qualities, so that it is encoded as null in JSON.
+func jsonQual(s Stats, q int) *int {
+	if s.NQual == 0 {
+		return nil
+	}
+	return &q
+}
+
+// textQual formats the quality value v of s with format, or returns NA
+// if s has no qualities.
+func textQual(s Stats, format string, v interface{}) string {
+	if s.NQual == 0 {
+		return "NA"
+	}
+	return fmt.Sprintf(format, v)
+}
+
+func jsonFloats(values []float64) []jsonFloat {
+	f := make([]jsonFloat, len(values))
+	for i, v := range values {
+		f[i] = jsonFloat(v)
+	}
+	return f
+}
+
+type jsonHistogram struct {
+	Bins   []float64 `json:"bins"`
+	Counts []int     `json:"counts"`
+}
+
+type jsonPositions struct {
+	Read     int                    `json:"read"`
+	NReads   []int64                `json:"nreads"`
+	MeanQual []jsonFloat            `json:"mean_qual"`
+	Q1       []int                  `json:"q1"`
+	Median   []int                  `json:"median"`
+	Q3       []int                  `json:"q3"`
+	NtFreq   map[string][]jsonFloat `json:"nt_freq"`
+}
+
+type jsonAdapters struct {
+	Content           map[string]float64   `json:"content"`
+	ContentByPosition map[string][]float64 `json:"content_by_position"`
+	Inferred          *string              `json:"inferred"`
+	InferredFreq      *float64             `json:"inferred_freq"`
+}
+
+type jsonStats struct {
+	NSeq          int                  `json:"nseq"`
+	Paired        bool                 `json:"paired"`
+	NtFreq        map[string]jsonFloat `json:"nt_freq"`
+	Encoding      string               `json:"encoding"`
+	Candidates    []string             `json:"encoding_candidates,omitempty"`
+	MeanQual      jsonFloat            `json:"mean_qual"`
+	MinQual       *int                 `json:"min_qual"`
+	MaxQual       *int                 `json:"max_qual"`
+	QualHistogram *jsonHistogram       `json:"qual_histogram,omitempty"`
+	LenHistogram  *jsonHistogram       `json:"len_histogram,omitempty"`
+	PerPosition   []jsonPositions      `json:"per_position,omitempty"`
+	Adapters      *jsonAdapters        `json:"adapters,omitempty"`
+	GC            *jsonGC              `json:"gc,omitempty"`
+	Duplication   *jsonDuplication     `json:"duplication,omitempty"`
+	Read1         *jsonStats           `json:"read1,omitempty"`
+	Read2         *jsonStats           `json:"read2,omitempty"`
+}
+
+func newJSONHistogram(h *hist.IntHistogram) *jsonHistogram {
+	if h == nil {
+		return nil
+	}
+	bins, counts := h.Bins()
+	return &jsonHistogram{bins, counts}
+}
+
+func newJSONPositions(read int, ps *PositionStats) jsonPositions {
+	jp := jsonPositions{
+		Read:     read,
+		NReads:   ps.NReads,
+		MeanQual: jsonFloats(ps.MeanQual),
+		Q1:       ps.Q1,
+		Median:   ps.Median,
+		Q3:       ps.Q3,
+		NtFreq:   make(map[string][]jsonFloat),
+	}
+	for i := 0; i < 5; i++ {
+		nt, _ := fastq.Nt(i)
+		freqs := make([]jsonFloat, len(ps.NtFreq))
+		for p := range ps.NtFreq {
+			freqs[p] = jsonFloat(ps.NtFreq[p][i])
+		}
+		jp.NtFreq[string(nt)] = freqs
+	}
+	return jp
+}
+
+type jsonGC struct {
+	Mean         jsonFloat   `json:"mean"`
+	Mode         jsonFloat   `json:"mode"`
+	SD           jsonFloat   `json:"sd"`
+	Deviation    jsonFloat   `json:"deviation"`
+	Peaks        []int       `json:"peaks"`
+	Suspicious   bool        `json:"suspicious"`
+	Distribution []jsonFloat `json:"distribution"`
+	Theoretical  []jsonFloat `json:"theoretical"`
+}
+
+func newJSONGC(gc *GCContent) *jsonGC {
+	mode, sd, theo := gc.Theoretical()
+	return &jsonGC{
+		Mean:         jsonFloat(gc.Mean()),
+		Mode:         jsonFloat(mode),
+		SD:           jsonFloat(sd),
+		Deviation:    jsonFloat(gc.Deviation()),
+		Peaks:        append([]int{}, gc.Peaks()...),
+		Suspicious:   gc.Suspicious(),
+		Distribution: jsonFloats(gc.Distribution()),
+		Theoretical:  jsonFloats(theo),
+	}
+}
+
+// topOverRepresented is the number of over-represented sequences
+// written in reports.
+const topOverRepresented = 10
+
+type jsonOverRepresented struct {
+	Sequence string  `json:"sequence"`
+	Count    int64   `json:"count"`
+	Fraction float64 `json:"fraction"`
+}
+
+type jsonDuplicationCounter struct {
+	Distinct        jsonFloat             `json:"distinct"`
+	Levels          map[string]jsonFloat  `json:"levels"`
+	OverRepresented []jsonOverRepresented `json:"over_represented"`
+}
+
+type jsonDuplication struct {
+	Reads *jsonDuplicationCounter `json:"reads"`
+	Pairs *jsonDuplicationCounter `json:"pairs,omitempty"`
+}
+
+func newJSONDuplicationCounter(dc *DuplicationCounter) *jsonDuplicationCounter {
+	if dc == nil {
+		return nil
+	}
+	levels, distinct := dc.Levels()
+	jd := &jsonDuplicationCounter{
+		Distinct:        jsonFloat(distinct),
+		Levels:          make(map[string]jsonFloat),
+		OverRepresented: []jsonOverRepresented{},
+	}
+	for i, l := range levels {
+		jd.Levels[DuplicationLabels[i]] = jsonFloat(l)
+	}
+	for _, o := range dc.OverRepresented(topOverRepresented) {
+		jd.OverRepresented = append(jd.OverRepresented, jsonOverRepresented(o))
+	}
+	return jd
+}
+
+func newJSONAdapters(ac *AdapterContent) *jsonAdapters {
+	ja := &jsonAdapters{
+		Content:           make(map[string]float64),
+		ContentByPosition: make(map[string][]float64),
+	}
+	content := ac.Content()
+	for i, t := range ac.Total() {
+		ja.Content[ac.Adapters[i].Name] = t
+		ja.ContentByPosition[ac.Adapters[i].Name] = content[i]
+	}
+	if !ac.Found() {
+		if adapter, freq := ac.Infer(); adapter != nil {
+			inferred := string(adapter)
+			ja.Inferred, ja.InferredFreq = &inferred, &freq
+		}
+	}
+	return ja
+}
+
+func newJSONStats(s Stats) jsonStats {
+	js := jsonStats{
+		NSeq:          s.NSeq,
+		Paired:        s.Paired,
+		NtFreq:        make(map[string]jsonFloat),
+		Encoding:      s.Encoding.String(),
+		MeanQual:      jsonFloat(s.MeanQual),
+		MinQual:       jsonQual(s, s.MinQual),
+		MaxQual:       jsonQual(s, s.MaxQual),
+		QualHistogram: newJSONHistogram(s.QualHistogram),
+		LenHistogram:  newJSONHistogram(s.LenHistogram),
+	}
+	for i, v := range s.TotalNt {
+		nt, _ := fastq.Nt(i)
+		js.NtFreq[string(nt)] = jsonFloat(v)
+	}
+	for _, c := range s.EncodingCandidates {
+		js.Candidates = append(js.Candidates, c.String())
+	}
+	if s.PerPosition1 != nil {
+		js.PerPosition = append(js.PerPosition, newJSONPositions(1, s.PerPosition1))
+	}
+	if s.PerPosition2 != nil {
+		js.PerPosition = append(js.PerPosition, newJSONPositions(2, s.PerPosition2))
+	}
+	if s.Adapters != nil {
+		js.Adapters = newJSONAdapters(s.Adapters)
+	}
+	if s.GC != nil {
+		js.GC = newJSONGC(s.GC)
+	}
+	if s.Duplication != nil {
+		js.Duplication = &jsonDuplication{
+			Reads: newJSONDuplicationCounter(s.Duplication.Reads),
+			Pairs: newJSONDuplicationCounter(s.Duplication.Pairs),
+		}
+	}
+	if s.Read1 != nil {
+		js1, js2 := newJSONStats(*s.Read1), newJSONStats(*s.Read2)
+		js.Read1, js.Read2 = &js1, &js2
+	}
+	return js
+}
+
+// WriteJSON writes s as a JSON object, with the following keys:
+//
+//	nseq            number of records (pairs for paired-end input)
+//	paired          true for paired-end input
+//	nt_freq         fraction of each nucleotide: {"A": f, "C": f, "G": f, "T": f, "N": f}
+//	encoding        quality encoding name ("Unknown" if no encoding
+//	                matches, or if the encoding is ambiguous)
+//	encoding_candidates
+//	                names of the encodings matching the qualities
+//	                (absent without qualities)
+//	mean_qual       mean base quality (null without qualities)
+//	min_qual        minimum base quality (null without qualities)
+//	max_qual        maximum base quality (null without qualities)
+//	qual_histogram  if computed: {"bins": [middle of bins], "counts": [counts]}
+//	len_histogram   same, for read lengths
+//	per_position    if computed, one object per read of pairs, with
+//	                "read" (1 or 2) and, by position, "nreads",
+//	                "mean_qual", "q1", "median", "q3", and "nt_freq"
+//	                ({"A": [f...], ...})
+//	adapters        if computed: "content" (fraction of reads containing
+//	                each known adapter), "content_by_position"
+//	                (cumulative fraction by position for each adapter),
+//	                "inferred" and "inferred_freq" (inferred adapter if
+//	                no known adapter is found, null otherwise)
+//	gc              if computed: "mean", "mode" and "sd" (GC percentage
+//	                of reads), "deviation" (fraction of reads outside the
+//	                fitted normal distribution), "peaks" (GC percentages
+//	                of the peaks), "suspicious" (see GCContent.Suspicious),
+//	                "distribution" and "theoretical" (fraction of reads
+//	                by GC percentage, from 0 to 100)
+//	duplication     if computed: "reads" and, for paired-end input,
+//	                "pairs", with "distinct" (estimated fraction of
+//	                distinct sequences), "levels" (fraction of sequences
+//	                by duplication level: {"1": f, "2": f, "3-9": f, ...})
+//	                and "over_represented" (most frequent sequences, with
+//	                "sequence", "count" and "fraction")
+//	read1, read2    for paired-end input, statistics of first reads and
+//	                of second reads, with keys nseq to len_histogram
+//
+// Optional keys are omitted when not computed. Fractions are between
+// 0 and 1.
+func WriteJSON(w goio.Writer, s Stats) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(newJSONStats(s))
+}
+
+// WriteTSV writes s as a long table with three columns: section, key
+// and value. Sections are:
+//
+//	summary                   keys nseq, paired, encoding, encoding_candidates (comma
+//	                          separated), mean_qual, min_qual, max_qual
+//	nt_freq                   keys A, C, G, T, N
+//	qual_histogram            keys are middle of bins, values are counts
+//	len_histogram             same, for read lengths
+//	per_position_r<read>.<m>  keys are positions (starting at 1), m is one
+//	                          of nreads, mean_qual, q1, median, q3, A, C, G, T, N
+//	adapter_content           keys are adapter names, values are fractions of reads
+//	adapter_content.<name>    keys are positions, values are cumulative fractions
+//	inferred_adapter          keys sequence and freq, if an adapter is inferred
+//	gc_content                keys mean, mode, sd, deviation, peaks (comma separated) and suspicious
+//	gc_distribution           keys are GC percentages, values are fractions of reads
+//	gc_theoretical            same, for the fitted normal distribution
+//	duplication_<u>           key distinct, u is reads or pairs
+//	duplication_<u>.levels    keys are duplication levels, values are fractions of sequences
+//	over_represented_<u>      keys are sequences, values are counts
+//
+// For paired-end input, sections summary to len_histogram are also
+// written for first reads and second reads, prefixed by read1. and
+// read2. (e.g. read1.summary).
+//
+// Fractions are between 0 and 1. Missing qualities are written NaN.
+func WriteTSV(w goio.Writer, s Stats) error {
+	js := newJSONStats(s)
+	bw := bufio.NewWriter(w)
+	row := func(section, key string, value interface{}) {
+		switch v := value.(type) {
+		case jsonFloat:
+			value = float64(v)
+		case *int:
+			if value = math.NaN(); v != nil {
+				value = *v
+			}
+		}
+		fmt.Fprintf(bw, "%s\t%s\t%v\n", section, key, value)
+	}
+	basic := func(prefix string, js *jsonStats) {
+		row(prefix+"summary", "nseq", js.NSeq)
+		row(prefix+"summary", "paired", js.Paired)
+		row(prefix+"summary", "encoding", js.Encoding)
+		if prefix == "" {
+			row(prefix+"summary", "encoding_candidates", strings.Join(js.Candidates, ","))
+		}
+		row(prefix+"summary", "mean_qual", js.MeanQual)
+		row(prefix+"summary", "min_qual", js.MinQual)
+		row(prefix+"summary", "max_qual", js.MaxQual)
+		for _, nt := range "ACGTN" {
+			row(prefix+"nt_freq", string(nt), js.NtFreq[string(nt)])
+		}
+		for _, h := range []struct {
+			name string
+			h    *jsonHistogram
+		}{{"qual_histogram", js.QualHistogram}, {"len_histogram", js.LenHistogram}} {
+			if h.h == nil {
+				continue
+			}
+			for i, b := range h.h.Bins {
+				row(prefix+h.name, strconv.FormatFloat(b, 'f', 2, 64), h.h.Counts[i])
+			}
+		}
+	}
+	fmt.Fprintln(bw, "section\tkey\tvalue")
+	basic("", &js)
+	if js.Read1 != nil {
+		basic("read1.", js.Read1)
+		basic("read2.", js.Read2)
+	}
+	for _, jp := range js.PerPosition {
+		prefix := fmt.Sprintf("per_position_r%d.", jp.Read)
+		for p := range jp.NReads {
+			pos := strconv.Itoa(p + 1)
+			row(prefix+"nreads", pos, jp.NReads[p])
+			row(prefix+"mean_qual", pos, jp.MeanQual[p])
+			row(prefix+"q1", pos, jp.Q1[p])
+			row(prefix+"median", pos, jp.Median[p])
+			row(prefix+"q3", pos, jp.Q3[p])
+			for _, nt := range "ACGTN" {
+				row(prefix+string(nt), pos, jp.NtFreq[string(nt)][p])
+			}
+		}
+	}
+	if ac := s.Adapters; ac != nil {
+		for _, a := range ac.Adapters {
+			row("adapter_content", a.Name, js.Adapters.Content[a.Name])
+		}
+		for _, a := range ac.Adapters {
+			for p, v := range js.Adapters.ContentByPosition[a.Name] {
+				row("adapter_content."+a.Name, strconv.Itoa(p+1), v)
+			}
+		}
+		if js.Adapters.Inferred != nil {
+			row("inferred_adapter", "sequence", *js.Adapters.Inferred)
+			row("inferred_adapter", "freq", *js.Adapters.InferredFreq)
+		}
+	}
+	if jg := js.GC; jg != nil {
+		peaks := make([]string, len(jg.Peaks))
+		for i, p := range jg.Peaks {
+			peaks[i] = strconv.Itoa(p)
+		}
+		row("gc_content", "mean", jg.Mean)
+		row("gc_content", "mode", jg.Mode)
+		row("gc_content", "sd", jg.SD)
+		row("gc_content", "deviation", jg.Deviation)
+		row("gc_content", "peaks", strings.Join(peaks, ","))
+		row("gc_content", "suspicious", jg.Suspicious)
+		for p := range jg.Distribution {
+			row("gc_distribution", strconv.Itoa(p), jg.Distribution[p])
+		}
+		for p := range jg.Theoretical {
+			row("gc_theoretical", strconv.Itoa(p), jg.Theoretical[p])
+		}
+	}
+	if jd := js.Duplication; jd != nil {
+		for _, u := range []struct {
+			name string
+			jd   *jsonDuplicationCounter
+		}{{"reads", jd.Reads}, {"pairs", jd.Pairs}} {
+			if u.jd == nil {
+				continue
+			}
+			row("duplication_"+u.name, "distinct", u.jd.Distinct)
+			for _, l := range DuplicationLabels {
+				row("duplication_"+u.name+".levels", l, u.jd.Levels[l])
+			}
+			for _, o := range u.jd.OverRepresented {
+				row("over_represented_"+u.name, o.Sequence, o.Count)
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// WriteMultiQC writes the main statistics of s as a MultiQC custom
+// content file (General Statistics table), for the given sample.
+// The output must be saved in a file whose name ends with _mqc.json
+// to be found by MultiQC.
+func WriteMultiQC(w goio.Writer, s Stats, sample string) error {
+	return writeMultiQC(w, []SampleStats{{sample, s}})
+}
+
+func writeMultiQC(w goio.Writer, samples []SampleStats) error {
+	type column struct {
+		Title       string  `json:"title"`
+		Description string  `json:"description"`
+		Format      string  `json:"format,omitempty"`
+		Suffix      string  `json:"suffix,omitempty"`
+		Max         float64 `json:"max,omitempty"`
+	}
+	pconfig := []map[string]column{
+		{"nseq": {Title: "Reads", Description: "Number of reads (pairs for paired-end data)", Format: "{:,.0f}"}},
+		{"mean_qual": {Title: "Mean Qual", Description: "Mean base quality", Format: "{:,.1f}"}},
+		{"percent_gc": {Title: "% GC", Description: "Percentage of G and C bases", Suffix: "%", Max: 100}},
+		{"percent_n": {Title: "% N", Description: "Percentage of N bases", Suffix: "%", Max: 100}},
+	}
+	adapters, gc, dup := false, false, false
+	data := make(map[string]interface{})
+	for _, ss := range samples {
+		s := ss.Stats
+		d := map[string]interface{}{
+			"nseq":       s.NSeq,
+			"mean_qual":  jsonFloat(s.MeanQual),
+			"percent_gc": jsonFloat(100 * (s.TotalNt[1] + s.TotalNt[2])),
+			"percent_n":  jsonFloat(100 * s.TotalNt[4]),
+		}
+		if s.Adapters != nil {
+			content := 0.0
+			for _, t := range s.Adapters.Total() {
+				content = math.Max(content, t)
+			}
+			d["percent_adapter"] = jsonFloat(100 * content)
+			adapters = true
+		}
+		if s.GC != nil {
+			d["gc_deviation"] = jsonFloat(100 * s.GC.Deviation())
+			gc = true
+		}
+		if s.Duplication != nil {
+			// Duplication of pairs for paired-end data
+			dc := s.Duplication.Reads
+			if s.Duplication.Pairs != nil {
+				dc = s.Duplication.Pairs
+			}
+			_, distinct := dc.Levels()
+			d["percent_duplicates"] = jsonFloat(100 * (1 - distinct))
+			dup = true
+		}
+		data[ss.Sample] = d
+	}
+	if adapters {
+		pconfig = append(pconfig, map[string]column{"percent_adapter": {Title: "% Adapter", Description: "Percentage of reads containing the most frequent known adapter", Suffix: "%", Max: 100}})
+	}
+	if gc {
+		pconfig = append(pconfig, map[string]column{"gc_deviation": {Title: "GC Dev", Description: "Percentage of reads outside the normal distribution fitted to the per-read GC content", Suffix: "%", Max: 100}})
+	}
+	if dup {
+		pconfig = append(pconfig, map[string]column{"percent_duplicates": {Title: "% Dups", Description: "Estimated percentage of duplicate reads (pairs for paired-end data)", Suffix: "%", Max: 100}})
+	}
+	mqc := map[string]interface{}{
+		"id":           "fastqutils_stats",
+		"section_name": "fastqutils",
+		"description":  "Statistics computed by fastqutils stats",
+		"plot_type":    "generalstats",
+		"pconfig":      pconfig,
+		"data":         data,
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(mqc)
+}
+
+// SampleStats are the statistics of a named sample.
+type SampleStats struct {
+	Sample string
+	Stats  Stats
+}
+
+// WriteSummary writes the statistics of several samples, and their
+// aggregate (see Merge), in the given format:
+//
+//   - TEXT and TSV: a table with one row per sample, and a last row
+//     for the aggregate, named "all". Columns are sample, nseq, paired,
+//     A, C, G, T, N (fractions), encoding, mean_qual, min_qual and
+//     max_qual.
+//   - JSON: an object with keys "samples", an array of objects with
+//     keys "sample" and "stats" (see WriteJSON), and "aggregate".
+//   - MULTIQC: MultiQC custom content with one row per sample (see
+//     WriteMultiQC).
+func WriteSummary(w goio.Writer, samples []SampleStats, aggregate Stats, format int) error {
+	switch format {
+	case TEXT, TSV:
+		bw := bufio.NewWriter(w)
+		fmt.Fprintln(bw, "sample\tnseq\tpaired\tA\tC\tG\tT\tN\tencoding\tmean_qual\tmin_qual\tmax_qual")
+		for _, ss := range append(samples[:len(samples):len(samples)], SampleStats{"all", aggregate}) {
+			s := ss.Stats
+			fmt.Fprintf(bw, "%s\t%d\t%v", ss.Sample, s.NSeq, s.Paired)
+			for _, f := range s.TotalNt {
+				fmt.Fprintf(bw, "\t%.4f", f)
+			}
+			fmt.Fprintf(bw, "\t%s\t%s\t%s\t%s\n", s.Encoding, textQual(s, "%.3f", s.MeanQual), textQual(s, "%d", s.MinQual), textQual(s, "%d", s.MaxQual))
+		}
+		return bw.Flush()
+	case JSON:
+		type jsonSample struct {
+			Sample string    `json:"sample"`
+			Stats  jsonStats `json:"stats"`
+		}
+		summary := struct {
+			Samples   []jsonSample `json:"samples"`
+			Aggregate jsonStats    `json:"aggregate"`
+		}{Aggregate: newJSONStats(aggregate)}
+		for _, ss := range samples {
+			summary.Samples = append(summary.Samples, jsonSample{ss.Sample, newJSONStats(ss.Stats)})
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(summary)
+	case MULTIQC:
+		return writeMultiQC(w, samples)
+	}
+	return fmt.Errorf("unknown output format code : %d", format)
+}
+
+// WriteText writes s in a human readable format: key/value lines,
+// followed by ASCII histograms and tables of computed optional
+// statistics. For paired-end input, values of all reads are followed
+// by values of first reads and of second reads, on the same line.
+func WriteText(w goio.Writer, s Stats) (err error) {
+	var nt byte
+
+	bw := bufio.NewWriter(w)
+	columns := []Stats{s}
+	if s.Read1 != nil {
+		columns = append(columns, *s.Read1, *s.Read2)
+	}
+	row := func(key, format string, value func(s Stats) interface{}) {
+		fmt.Fprint(bw, key)
+		for _, c := range columns {
+			fmt.Fprintf(bw, "\t"+format, value(c))
+		}
+		fmt.Fprintln(bw)
+	}
+
+	if s.Read1 != nil {
+		fmt.Fprintln(bw, "\tAll\tR1\tR2")
+	}
+	row("NSeq", "%d", func(s Stats) interface{} { return s.NSeq })
+	fmt.Fprintf(bw, "Paired\t%v\n", s.Paired)
+	for i := range s.TotalNt {
+		nt, _ = fastq.Nt(i)
+		row(string(nt), "%.2f", func(s Stats) interface{} { return s.TotalNt[i] })
+	}
+	fmt.Fprintf(bw, "Encoding\t%s\n", s.Encoding)
+	if s.Encoding == UNKNOWN && len(s.EncodingCandidates) > 1 {
+		// Ambiguous encoding
+		fmt.Fprint(bw, "EncodingCandidates\t")
+		for i, c := range s.EncodingCandidates {
+			name := c.String()
+			if i > 0 {
+				fmt.Fprint(bw, ",")
+			}
+			fmt.Fprint(bw, name)
+		}
+		fmt.Fprintln(bw)
+	}
+	row("AvgQual", "%s", func(s Stats) interface{} { return textQual(s, "%.3f", s.MeanQual) })
+	row("MinQual", "%s", func(s Stats) interface{} { return textQual(s, "%d", s.MinQual) })
+	row("MaxQual", "%s", func(s Stats) interface{} { return textQual(s, "%d", s.MaxQual) })
+	if s.QualHistogram != nil {
+		fmt.Fprintf(bw, "Quality Histogram\n%s\n", s.QualHistogram.Draw(100))
+		if s.Read1 != nil {
+			fmt.Fprintf(bw, "Quality Histogram (R1)\n%s\n", s.Read1.QualHistogram.Draw(100))
+			fmt.Fprintf(bw, "Quality Histogram (R2)\n%s\n", s.Read2.QualHistogram.Draw(100))
+		}
+	}
+	if s.LenHistogram != nil {
+		fmt.Fprintf(bw, "Length Histogram\n%s\n", s.LenHistogram.Draw(100))
+		if s.Read1 != nil {
+			fmt.Fprintf(bw, "Length Histogram (R1)\n%s\n", s.Read1.LenHistogram.Draw(100))
+			fmt.Fprintf(bw, "Length Histogram (R2)\n%s\n", s.Read2.LenHistogram.Draw(100))
+		}
+	}
+	if s.PerPosition1 != nil {
+		writePerPosition(bw, "first reads", s.PerPosition1)
+	}
+	if s.PerPosition2 != nil {
+		writePerPosition(bw, "second reads", s.PerPosition2)
+	}
+	if s.Adapters != nil {
+		writeAdapterContent(bw, s.Adapters)
+	}
+	if s.GC != nil {
+		writeGCContent(bw, s.GC)
+	}
+	if s.Duplication != nil {
+		writeDuplication(bw, "reads", s.Duplication.Reads)
+		if s.Duplication.Pairs != nil {
+			writeDuplication(bw, "pairs", s.Duplication.Pairs)
+		}
+	}
+	return bw.Flush()
+}
+
+// writePerPosition writes per-position statistics as a table.
+func writePerPosition(w goio.Writer, title string, ps *PositionStats) {
+	fmt.Fprintf(w, "Per Position (%s)\n", title)
+	fmt.Fprintln(w, "Position\tNReads\tMeanQual\tQ1\tMedian\tQ3\tA\tC\tG\tT\tN")
+	for p := range ps.NReads {
+		fmt.Fprintf(w, "%d\t%d\t%.3f\t%d\t%d\t%d", p+1, ps.NReads[p], ps.MeanQual[p], ps.Q1[p], ps.Median[p], ps.Q3[p])
+		for _, f := range ps.NtFreq[p] {
+			fmt.Fprintf(w, "\t%.2f", f)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// writeAdapterContent writes the percentage of reads containing each
+// known adapter, the inferred adapter if none of them is found, and
+// the adapter content by position.
+func writeAdapterContent(w goio.Writer, ac *AdapterContent) {
+	for i, t := range ac.Total() {
+		fmt.Fprintf(w, "Adapter\t%s\t%.2f\n", ac.Adapters[i].Name, 100*t)
+	}
+	if !ac.Found() {
+		if adapter, freq := ac.Infer(); adapter != nil {
+			fmt.Fprintf(w, "InferredAdapter\t%s\t%.2f\n", adapter, 100*freq)
+		} else {
+			fmt.Fprintf(w, "InferredAdapter\tnone\n")
+		}
+	}
+
+	fmt.Fprint(w, "Adapter Content\nPosition")
+	for _, a := range ac.Adapters {
+		fmt.Fprintf(w, "\t%s", a.Name)
+	}
+	fmt.Fprintln(w)
+	content := ac.Content()
+	for p := 0; p < ac.Length; p++ {
+		fmt.Fprint(w, p+1)
+		for i := range ac.Adapters {
+			fmt.Fprintf(w, "\t%.2f", 100*content[i][p])
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// writeGCContent writes the summary of the GC content distribution,
+// its histogram, and the observed and theoretical fractions of reads
+// (in percent) by GC percentage.
+func writeGCContent(w goio.Writer, gc *GCContent) {
+	mode, sd, theo := gc.Theoretical()
+	fmt.Fprintf(w, "GCMean\t%.2f\n", gc.Mean())
+	fmt.Fprintf(w, "GCMode\t%.1f\n", mode)
+	fmt.Fprintf(w, "GCStdDev\t%.2f\n", sd)
+	fmt.Fprintf(w, "GCDeviation\t%.2f\n", 100*gc.Deviation())
+	fmt.Fprint(w, "GCPeaks\t")
+	for i, p := range gc.Peaks() {
+		if i > 0 {
+			fmt.Fprint(w, ",")
+		}
+		fmt.Fprint(w, p)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "GCSuspicious\t%v\n", gc.Suspicious())
+	fmt.Fprintf(w, "GC Content Histogram\n%s\n", gc.Histogram().Draw(100))
+
+	fmt.Fprintln(w, "GC Content\nGC\tObserved\tTheoretical")
+	for p, f := range gc.Distribution() {
+		fmt.Fprintf(w, "%d\t%.2f\t%.2f\n", p, 100*f, 100*theo[p])
+	}
+}
+
+// writeDuplication writes the estimated fraction of distinct
+// sequences, the fraction of sequences by duplication level and the
+// most over-represented sequences (in percent), for the given unit
+// (reads or pairs).
+func writeDuplication(w goio.Writer, unit string, dc *DuplicationCounter) {
+	levels, distinct := dc.Levels()
+	fmt.Fprintf(w, "Distinct%s%s\t%.2f\n", strings.ToUpper(unit[:1]), unit[1:], 100*distinct)
+	fmt.Fprintf(w, "Duplication Levels (%s)\nLevel\tPercent\n", unit)
+	for i, l := range levels {
+		fmt.Fprintf(w, "%s\t%.2f\n", DuplicationLabels[i], 100*l)
+	}
+	fmt.Fprintf(w, "Over-represented %s\nSequence\tCount\tPercent\n", unit)
+	for _, o := range dc.OverRepresented(topOverRepresented) {
+		fmt.Fprintf(w, "%s\t%d\t%.2f\n", o.Sequence, o.Count, 100*o.Fraction)
+	}
+}
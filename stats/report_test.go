@@ -0,0 +1,77 @@
+package stats
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/fredericlemoine/fastqutils/fastq"
+	"github.com/fredericlemoine/fastqutils/io"
+)
+
+func TestWriteReport(t *testing.T) {
+	// Fasta input: qualities are missing
+	entries := []*fastq.FastqEntry{{Name: []byte("@r1"), Sequence: []byte("ACGT")}, {Name: []byte("@r2"), Sequence: []byte("GGCC")}}
+	s, err := ComputeStats(io.NewMemoryReader(entries, nil), true, true, NewAdapterContent())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	if err = WriteJSON(&b, s); err != nil {
+		t.Fatal(err)
+	}
+	var js map[string]interface{}
+	if err = json.Unmarshal(b.Bytes(), &js); err != nil {
+		t.Fatalf("invalid json output: %v\n%s", err, b.String())
+	}
+	if js["nseq"] != 2.0 || js["mean_qual"] != nil || js["min_qual"] != nil || js["max_qual"] != nil || js["encoding_candidates"] != nil || js["nt_freq"].(map[string]interface{})["G"] != 0.375 {
+		t.Errorf("unexpected json output: %s", b.String())
+	}
+	for _, key := range []string{"qual_histogram", "len_histogram", "per_position", "adapters"} {
+		if _, ok := js[key]; !ok {
+			t.Errorf("missing key %s in json output", key)
+		}
+	}
+
+	b.Reset()
+	if err = WriteText(&b, s); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "Encoding\tUnknown\nAvgQual\tNA\nMinQual\tNA\nMaxQual\tNA\n") {
+		t.Errorf("qualities must be missing in text output: %s", b.String())
+	}
+
+	b.Reset()
+	if err = WriteTSV(&b, s); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if lines[0] != "section\tkey\tvalue" || lines[1] != "summary\tnseq\t2" {
+		t.Errorf("unexpected tsv output: %s", b.String())
+	}
+	for _, l := range lines {
+		if strings.Count(l, "\t") != 2 {
+			t.Errorf("tsv line does not have 3 columns: %q", l)
+		}
+	}
+	if !strings.Contains(b.String(), "per_position_r1.G\t2\t0.5\n") {
+		t.Errorf("missing per-position row in tsv output: %s", b.String())
+	}
+
+	b.Reset()
+	if err = WriteMultiQC(&b, s, "sample1"); err != nil {
+		t.Fatal(err)
+	}
+	var mqc struct {
+		PlotType string                            `json:"plot_type"`
+		Data     map[string]map[string]interface{} `json:"data"`
+	}
+	if err = json.Unmarshal(b.Bytes(), &mqc); err != nil {
+		t.Fatalf("invalid multiqc output: %v\n%s", err, b.String())
+	}
+	if mqc.PlotType != "generalstats" || mqc.Data["sample1"]["percent_gc"] != 75.0 {
+		t.Errorf("unexpected multiqc output: %s", b.String())
+	}
+}
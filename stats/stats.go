@@ -1,21 +1,49 @@
 package stats
 
 import (
+	"math"
+
 	"github.com/fredericlemoine/fastqutils/fastq"
 	"github.com/fredericlemoine/fastqutils/hist"
 	"github.com/fredericlemoine/fastqutils/io"
 )
 
 type Stats struct {
-	NSeq          int       // Number of sequences
-	Paired        bool      // If the Fastq are paired end
-	TotalNt       []float64 // global % of A / C / G / T
-	MeanQual      float64   // Average base quality
-	MinQual       int       // Min quality score
-	MaxQual       int       // Max quality score
-	Encoding      int       // Quality encoding
-	QualHistogram *hist.IntHistogram
-	LenHistogram  *hist.IntHistogram
+	NSeq               int        // Number of sequences
+	Paired             bool       // If the Fastq are paired end
+	TotalNt            []float64  // global % of A / C / G / T
+	NQual              int64      // Number of base qualities (0 without qualities, e.g. fasta input)
+	MeanQual           float64    // Average base quality (NaN without qualities)
+	MinQual            int        // Min quality score (0 without qualities)
+	MaxQual            int        // Max quality score (0 without qualities)
+	Encoding           Encoding   // Quality encoding
+	EncodingCandidates []Encoding // Encodings matching the range of qualities (see EncodingCandidates)
+	QualHistogram      *hist.IntHistogram
+	LenHistogram       *hist.IntHistogram
+	PerPosition1       *PositionStats  // Per-position statistics of first reads, if computed
+	PerPosition2       *PositionStats  // Per-position statistics of second reads, if computed and paired
+	Adapters           *AdapterContent // Adapter content, if given as a module to ComputeStats
+	GC                 *GCContent      // GC content distribution, if given as a module to ComputeStats
+	Duplication        *Duplication    // Duplication levels, if given as a module to ComputeStats
+	Read1              *Stats          // Statistics of first reads only, for paired-end input
+	Read2              *Stats          // Statistics of second reads only, for paired-end input
+
+	counts *counts // Raw counts, nil if Stats were not computed by ComputeStats or Merge
+}
+
+// Module is an optional statistic computed by ComputeStats, in
+// addition to the global statistics. Add is called on every read (both
+// reads of pairs). The entry is only valid during the call.
+type Module interface {
+	Add(entry *fastq.FastqEntry)
+}
+
+// PairModule is a Module that also computes statistics on pairs of
+// reads. For paired-end input, AddPair is called on every pair, after
+// Add on both reads. The entries are only valid during the call.
+type PairModule interface {
+	Module
+	AddPair(entry1, entry2 *fastq.FastqEntry)
 }
 
 func min(a, b int) int {
@@ -32,25 +60,151 @@ func max(a, b int) int {
 	return b
 }
 
-func ComputeStats(parser *io.FastQParser, histos bool) (s Stats, err error) {
-	var nbrecords int = 0
-	var paired bool = true
-	var totalNt []int64 = make([]int64, 5)
-	var freqNt []float64
-	var total int64 = 0
-	var meanQual float64
-	var minqual, maxqual int = 1000, 0
-	var nt int
-	var entry1, entry2 *fastq.FastqEntry
-	var qualHistogram, lenHistogram *hist.IntHistogram
+// counts are the raw counts from which Stats are computed. They are
+// kept in Stats so that statistics of several inputs can be merged
+// (see Merge).
+type counts struct {
+	nbrecords     int
+	paired        bool
+	nt            [5]int64 // Counts of A, C, G, T and N (and other IUPAC codes)
+	total         int64    // Number of bases
+	sumQual       float64  // Sum of quality characters
+	totalQual     int64    // Number of quality characters
+	minqual       int      // Min quality character
+	maxqual       int      // Max quality character
+	qualHistogram *hist.IntHistogram
+	lenHistogram  *hist.IntHistogram
+	positions1    *positionCounter
+	positions2    *positionCounter
+	adapters      *AdapterContent
+	gc            *GCContent
+	duplication   *Duplication
+	mate1, mate2  *counts // Counts of first and second reads only
+}
 
+// newCounts returns empty counts, with histograms if histos is true.
+func newCounts(histos bool) *counts {
+	c := &counts{
+		paired:  true,
+		minqual: 1000,
+	}
 	if histos {
-		qualHistogram = hist.NewIntHistogram(30)
-		lenHistogram = hist.NewIntHistogram(20)
+		c.qualHistogram = hist.NewIntHistogram(30)
+		c.lenHistogram = hist.NewIntHistogram(20)
+	}
+	return c
+}
+
+// addRead adds the counts of one read.
+func (c *counts) addRead(entry *fastq.FastqEntry) {
+	if c.lenHistogram != nil {
+		c.lenHistogram.AddPoint(int(len(entry.Sequence)))
 	}
+	for i := 0; i < len(entry.Sequence); i++ {
+		nt, nterr := fastq.Index(entry.Sequence[i])
+		if nterr != nil {
+			// Other IUPAC codes are counted as N
+			nt = 4
+		}
+		c.nt[nt]++
+		c.total++
+	}
+	// Quality may be missing (e.g. fasta input)
+	for _, q := range entry.Quality {
+		c.sumQual += float64(int(q))
+		c.minqual = min(c.minqual, int(q))
+		c.maxqual = max(c.maxqual, int(q))
+		if c.qualHistogram != nil {
+			c.qualHistogram.AddPoint(int(q))
+		}
+		c.totalQual++
+	}
+}
+
+// stats computes the statistics from the counts, detecting the
+// quality encoding. Without qualities, the encoding is UNKNOWN, with
+// no candidate.
+func (c *counts) stats() (s Stats) {
+	if c.totalQual == 0 {
+		s = c.statsWithEncoding(UNKNOWN)
+	} else {
+		s = c.statsWithEncoding(DetectEncoding(c.minqual, c.maxqual))
+		s.EncodingCandidates = EncodingCandidates(c.minqual, c.maxqual)
+	}
+	if c.paired && c.mate1 != nil {
+		// Mates are decoded with the encoding of all reads
+		r1, r2 := c.mate1.statsWithEncoding(s.Encoding), c.mate2.statsWithEncoding(s.Encoding)
+		r1.Paired, r2.Paired = false, false
+		s.Read1, s.Read2 = &r1, &r2
+	}
+	return
+}
+
+// statsWithEncoding computes the statistics from the counts, with
+// the given quality encoding.
+func (c *counts) statsWithEncoding(encoding Encoding) (s Stats) {
+	freqNt := make([]float64, len(c.nt))
+	for i, v := range c.nt {
+		freqNt[i] = float64(v) / float64(c.total)
+	}
+
+	off := encoding.Offset()
 
+	s = Stats{
+		NSeq:          c.nbrecords,
+		Paired:        c.paired,
+		TotalNt:       freqNt,
+		NQual:         c.totalQual,
+		MeanQual:      math.NaN(),
+		Encoding:      encoding,
+		QualHistogram: c.qualHistogram,
+		LenHistogram:  c.lenHistogram,
+		Adapters:      c.adapters,
+		GC:            c.gc,
+		Duplication:   c.duplication,
+		counts:        c,
+	}
+	if c.totalQual > 0 {
+		s.MeanQual = c.sumQual/float64(c.totalQual) - float64(off)
+		s.MinQual, s.MaxQual = c.minqual-off, c.maxqual-off
+	}
+	if c.positions1 != nil {
+		s.PerPosition1 = c.positions1.stats(off)
+		if c.paired {
+			s.PerPosition2 = c.positions2.stats(off)
+		}
+	}
+	return
+}
+
+// ComputeStats computes the statistics of all the reads of parser, and
+// gives every read to the given modules. If perPosition is true,
+// per-position statistics are computed, separately for first and
+// second reads.
+func ComputeStats(parser io.Reader, histos, perPosition bool, modules ...Module) (s Stats, err error) {
+	var entry1, entry2 *fastq.FastqEntry
+
+	c := newCounts(histos)
+	c.mate1, c.mate2 = newCounts(histos), newCounts(histos)
+	if perPosition {
+		c.positions1 = &positionCounter{}
+		c.positions2 = &positionCounter{}
+	}
+	for _, m := range modules {
+		switch m := m.(type) {
+		case *AdapterContent:
+			c.adapters = m
+		case *GCContent:
+			c.gc = m
+		case *Duplication:
+			c.duplication = m
+		}
+	}
+
+	// Records are not kept, their buffers can be reused
+	parser = io.ReuseEntries(parser)
 	for {
-		entry1, entry2, err = parser.NextEntry()
+		entry1, entry2, err = parser.Next()
 		if err != nil {
 			if err.Error() != "EOF" {
 				return
@@ -59,62 +213,35 @@ func ComputeStats(parser *io.FastQParser, histos bool) (s Stats, err error) {
 			break
 		}
 
-		if histos {
-			lenHistogram.AddPoint(int(len(entry1.Sequence)))
+		c.addRead(entry1)
+		if entry2 != nil {
+			c.addRead(entry2)
+			c.mate1.addRead(entry1)
+			c.mate1.nbrecords++
+			c.mate2.addRead(entry2)
+			c.mate2.nbrecords++
 		}
-		for i := 0; i < len(entry1.Sequence); i++ {
-			nt, _ = fastq.Index(entry1.Sequence[i])
-			totalNt[nt]++
-			meanQual += float64(int(entry1.Quality[i]))
-			minqual = min(minqual, int(entry1.Quality[i]))
-			maxqual = max(maxqual, int(entry1.Quality[i]))
-			if histos {
-				qualHistogram.AddPoint(int(entry1.Quality[i]))
+		if perPosition {
+			c.positions1.add(entry1)
+			if entry2 != nil {
+				c.positions2.add(entry2)
 			}
-			total++
 		}
-		if entry2 != nil {
-			if histos {
-				lenHistogram.AddPoint(int(len(entry2.Sequence)))
-			}
-			for i := 0; i < len(entry2.Sequence); i++ {
-				nt, _ = fastq.Index(entry2.Sequence[i])
-				totalNt[nt]++
-				meanQual += float64(int(entry2.Quality[i]))
-				minqual = min(minqual, int(entry2.Quality[i]))
-				maxqual = max(maxqual, int(entry2.Quality[i]))
-				if histos {
-					qualHistogram.AddPoint(int(entry2.Quality[i]))
+		for _, m := range modules {
+			m.Add(entry1)
+			if entry2 != nil {
+				m.Add(entry2)
+				if pm, ok := m.(PairModule); ok {
+					pm.AddPair(entry1, entry2)
 				}
-				total++
 			}
 		}
 
 		if entry2 == nil {
-			paired = false
+			c.paired = false
 		}
-		nbrecords++
-	}
-	freqNt = make([]float64, len(totalNt))
-	for i, v := range totalNt {
-		freqNt[i] = float64(v) / float64(total)
+		c.nbrecords++
 	}
 
-	encoding := DetectEncoding(minqual, maxqual)
-
-	off, _ := EncodingOffset(encoding)
-
-	s = Stats{
-		nbrecords,
-		paired,
-		freqNt,
-		meanQual/float64(total) - float64(off),
-		minqual - off,
-		maxqual - off,
-		encoding,
-		qualHistogram,
-		lenHistogram,
-	}
-
-	return
+	return c.stats(), nil
 }
@@ -0,0 +1,49 @@
+package stats
+
+import (
+	"testing"
+
+	"github.com/fredericlemoine/fastqutils/fastq"
+	"github.com/fredericlemoine/fastqutils/io"
+)
+
+func TestMateStats(t *testing.T) {
+	var entries1, entries2 []*fastq.FastqEntry
+	for i := 0; i < 100; i++ {
+		entries1 = append(entries1, fastq.GenFastQEntry(50, i, 35, 74))
+		entries2 = append(entries2, fastq.GenFastQEntry(70, i, 40, 60))
+	}
+	s, err := ComputeStats(io.NewMemoryReader(entries1, entries2), true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Read1 == nil || s.Read2 == nil {
+		t.Fatal("missing first and second read statistics")
+	}
+	if s.NSeq != 100 || s.Read1.NSeq != 100 || s.Read2.NSeq != 100 {
+		t.Errorf("number of records: got %d, %d, %d, want 100", s.NSeq, s.Read1.NSeq, s.Read2.NSeq)
+	}
+	if s.Read1.Encoding != s.Encoding || s.Read2.Encoding != s.Encoding {
+		t.Errorf("mates must share the encoding of the input")
+	}
+	if s.Read2.MinQual < s.Read1.MinQual || s.Read2.MaxQual > s.Read1.MaxQual {
+		t.Errorf("quality ranges of mates are mixed up: R1 [%d,%d], R2 [%d,%d]",
+			s.Read1.MinQual, s.Read1.MaxQual, s.Read2.MinQual, s.Read2.MaxQual)
+	}
+	// The combined mean is weighted by the number of bases of each mate
+	mean := (s.Read1.MeanQual*50 + s.Read2.MeanQual*70) / 120
+	if d := s.MeanQual - mean; d > 1e-9 || d < -1e-9 {
+		t.Errorf("mean quality: got %f, want %f", s.MeanQual, mean)
+	}
+	if s.Read1.Read1 != nil {
+		t.Errorf("mate statistics must not be nested")
+	}
+
+	s, err = ComputeStats(io.NewMemoryReader(entries1, nil), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Read1 != nil || s.Read2 != nil {
+		t.Errorf("single-end input must not have mate statistics")
+	}
+}
@@ -0,0 +1,222 @@
+package trim
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/fredericlemoine/fastqutils/fastq"
+	"github.com/fredericlemoine/fastqutils/io"
+)
+
+// Adapter is a sequence to remove from the 3' end of reads.
+type Adapter struct {
+	Name     string
+	Sequence []byte
+}
+
+// Built-in adapters
+var (
+	TruSeqRead1 = Adapter{"TruSeq Read 1", []byte("AGATCGGAAGAGCACACGTCTGAACTCCAGTCA")}
+	TruSeqRead2 = Adapter{"TruSeq Read 2", []byte("AGATCGGAAGAGCGTCGTGTAGGGAAAGAGTGT")}
+	Nextera     = Adapter{"Nextera", []byte("CTGTCTCTTATACACATCT")}
+)
+
+// PresetAdapters returns the adapters to search in first and second
+// reads for the given preset: truseq, nextera, or none.
+func PresetAdapters(preset string) (adapters1, adapters2 []Adapter, err error) {
+	switch preset {
+	case "truseq":
+		adapters1 = []Adapter{TruSeqRead1}
+		adapters2 = []Adapter{TruSeqRead2}
+	case "nextera":
+		adapters1 = []Adapter{Nextera}
+		adapters2 = []Adapter{Nextera}
+	case "none":
+	default:
+		err = fmt.Errorf("this adapter preset does not exist : %s, possible values are : truseq, nextera, none", preset)
+	}
+	return
+}
+
+// ReadAdapters reads adapter sequences from a FASTA file.
+func ReadAdapters(file string) (adapters []Adapter, err error) {
+	var parser *io.FastaParser
+
+	if parser, err = io.NewSingleEndFastaParser(file); err != nil {
+		return
+	}
+	defer parser.Close()
+	err = io.ForEach(parser, func(entry, _ *fastq.FastqEntry) error {
+		// Names are returned with a leading '@'
+		name := string(entry.Name[1:])
+		if len(entry.Sequence) == 0 {
+			return fmt.Errorf("adapter %s has an empty sequence", name)
+		}
+		adapters = append(adapters, Adapter{
+			Name:     name,
+			Sequence: bytes.ToUpper(entry.Sequence),
+		})
+		return nil
+	})
+	return
+}
+
+// AdapterTrimmer removes adapters from the 3' end of reads.
+//
+// An adapter is found at a position of the read if the read from this
+// position matches the adapter (or, at the end of the read, the
+// beginning of the adapter, over at least MinOverlap bases) with at
+// most MaxMismatchRate mismatches per base. The read is cut at the
+// leftmost position where an adapter is found.
+//
+// For paired-end reads, if InsertOverlap is true, adapters are also
+// detected from the overlap of the two reads: when the insert is
+// shorter than the reads, the beginning of the first read is the
+// reverse complement of the beginning of the second read, and both
+// reads continue with adapters after the insert. This finds adapters
+// that are not known, or too short to be matched reliably.
+//
+// Hits are counted with atomic operations, so that Trim may be called
+// concurrently. Their counters are allocated at the first call to Trim
+// or Hits: Adapters1 and Adapters2 must not be modified afterwards.
+type AdapterTrimmer struct {
+	Adapters1       []Adapter // Adapters searched in first reads
+	Adapters2       []Adapter // Adapters searched in second reads
+	MaxMismatchRate float64   // Maximum number of mismatches per base of the match
+	MinOverlap      int       // Minimum length of a partial adapter at the end of a read
+	InsertOverlap   bool      // Detect adapters from paired-end insert overlap
+	MinInsert       int       // Minimum length of the insert overlap
+
+	hitsOnce     sync.Once // Allocates hits1 and hits2
+	hits1, hits2 []int64   // Number of reads trimmed by each adapter
+	overlapHits  int64     // Number of pairs trimmed by insert overlap
+}
+
+// NewAdapterTrimmer returns an AdapterTrimmer searching the given
+// adapters, with default parameters: 10% of mismatches, a minimum
+// overlap of 3 bases, and insert overlap detection of at least 20
+// bases.
+func NewAdapterTrimmer(adapters1, adapters2 []Adapter) *AdapterTrimmer {
+	return &AdapterTrimmer{
+		Adapters1:       adapters1,
+		Adapters2:       adapters2,
+		MaxMismatchRate: 0.1,
+		MinOverlap:      3,
+		InsertOverlap:   true,
+		MinInsert:       20,
+	}
+}
+
+// initHits allocates the hit counters of the adapters, so that
+// trimmers built without NewAdapterTrimmer can be used.
+func (t *AdapterTrimmer) initHits() {
+	t.hitsOnce.Do(func() {
+		t.hits1 = make([]int64, len(t.Adapters1))
+		t.hits2 = make([]int64, len(t.Adapters2))
+	})
+}
+
+// matches returns true if a and b (of the same length) differ by at
+// most rate mismatches per base. N in the adapter matches any base.
+func matches(a, b []byte, rate float64) bool {
+	max := int(rate * float64(len(a)))
+	mismatches := 0
+	for i := range a {
+		if a[i] != b[i] && a[i] != 'N' {
+			mismatches++
+			if mismatches > max {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// findAdapter returns the leftmost position of an adapter in seq and
+// the index of this adapter, or len(seq) and -1 if none is found.
+func (t *AdapterTrimmer) findAdapter(seq []byte, adapters []Adapter) (pos, index int) {
+	pos, index = len(seq), -1
+	for i, a := range adapters {
+		for p := 0; p < pos && len(seq)-p >= t.MinOverlap; p++ {
+			n := min(len(a.Sequence), len(seq)-p)
+			if matches(a.Sequence[:n], seq[p:p+n], t.MaxMismatchRate) {
+				pos, index = p, i
+				break
+			}
+		}
+	}
+	return
+}
+
+// findInsert returns the length of the insert of a pair of reads
+// overlapping each other, or -1 if the insert is not shorter than the
+// reads.
+func (t *AdapterTrimmer) findInsert(seq1, seq2 []byte) int {
+	n := min(len(seq1), len(seq2))
+	rc := append([]byte(nil), seq2[:n]...)
+	fastq.ReverseComplement(rc)
+	// The insert is seq1[:s], and rc[n-s:] is the reverse complement
+	// of seq2[:s]
+	for s := n - 1; s >= t.MinInsert && s > 0; s-- {
+		if matches(rc[n-s:], seq1[:s], t.MaxMismatchRate) {
+			return s
+		}
+	}
+	return -1
+}
+
+// Trim removes adapters from entry1 and from entry2 (nil for single-end
+// reads). The fields of the entries are resliced, not copied.
+func (t *AdapterTrimmer) Trim(entry1, entry2 *fastq.FastqEntry) {
+	t.initHits()
+	pos1, index1 := t.findAdapter(entry1.Sequence, t.Adapters1)
+	pos2, index2 := -1, -1
+	if entry2 != nil {
+		pos2, index2 = t.findAdapter(entry2.Sequence, t.Adapters2)
+		if t.InsertOverlap {
+			if s := t.findInsert(entry1.Sequence, entry2.Sequence); s >= 0 && (s < pos1 || s < pos2) {
+				atomic.AddInt64(&t.overlapHits, 1)
+				if s < pos1 {
+					pos1, index1 = s, -1
+				}
+				if s < pos2 {
+					pos2, index2 = s, -1
+				}
+			}
+		}
+		cut(entry2, pos2)
+	}
+	cut(entry1, pos1)
+	if index1 >= 0 {
+		atomic.AddInt64(&t.hits1[index1], 1)
+	}
+	if index2 >= 0 {
+		atomic.AddInt64(&t.hits2[index2], 1)
+	}
+}
+
+// cut keeps the first pos bases of entry.
+func cut(entry *fastq.FastqEntry, pos int) {
+	entry.Sequence = entry.Sequence[:pos]
+	if len(entry.Quality) > pos {
+		entry.Quality = entry.Quality[:pos]
+	}
+}
+
+// Hits returns the number of first reads and second reads trimmed by
+// each adapter of Adapters1 and Adapters2, and the number of pairs
+// trimmed using their insert overlap.
+func (t *AdapterTrimmer) Hits() (hits1, hits2 []int64, overlap int64) {
+	t.initHits()
+	hits1 = make([]int64, len(t.hits1))
+	hits2 = make([]int64, len(t.hits2))
+	for i := range hits1 {
+		hits1[i] = atomic.LoadInt64(&t.hits1[i])
+	}
+	for i := range hits2 {
+		hits2[i] = atomic.LoadInt64(&t.hits2[i])
+	}
+	return hits1, hits2, atomic.LoadInt64(&t.overlapHits)
+}
@@ -0,0 +1,90 @@
+package trim
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fredericlemoine/fastqutils/fastq"
+)
+
+func entry(seq string) *fastq.FastqEntry {
+	qual := make([]byte, len(seq))
+	for i := range qual {
+		qual[i] = 'I'
+	}
+	return &fastq.FastqEntry{Name: []byte("@read"), Sequence: []byte(seq), Quality: qual}
+}
+
+func TestAdapterTrimmerSingleEnd(t *testing.T) {
+	insert := "ACGTTGCAACGGTCAGTCAA"
+	tr := NewAdapterTrimmer([]Adapter{TruSeqRead1}, nil)
+	for _, c := range []struct {
+		seq  string
+		want string
+	}{
+		{insert + "AGATCGGAAGAGCACACG", insert},
+		// One mismatch over 18 bases
+		{insert + "AGATCGGTAGAGCACACG", insert},
+		// Two mismatches over 10 bases
+		{insert + "AGTTCGGTAG", insert + "AGTTCGGTAG"},
+		// Partial adapter at the end
+		{insert + "AGAT", insert},
+		{insert + "AG", insert + "AG"},
+		{"AGATCGGAAGAGCACACG", ""},
+		{insert, insert},
+	} {
+		e := entry(c.seq)
+		tr.Trim(e, nil)
+		if string(e.Sequence) != c.want || len(e.Quality) != len(c.want) {
+			t.Errorf("%s: got %s, want %s", c.seq, e.Sequence, c.want)
+		}
+	}
+	if hits1, _, _ := tr.Hits(); hits1[0] != 4 {
+		t.Errorf("got %d hits, want 4", hits1[0])
+	}
+
+	// Trimmer built without NewAdapterTrimmer
+	tr = &AdapterTrimmer{Adapters1: []Adapter{TruSeqRead1}, MinOverlap: 3}
+	tr.Trim(entry(insert+"AGATCGGAAGAGCACACG"), nil)
+	if hits1, _, _ := tr.Hits(); hits1[0] != 1 {
+		t.Errorf("struct literal: got %d hits, want 1", hits1[0])
+	}
+}
+
+func TestAdapterTrimmerInsertOverlap(t *testing.T) {
+	insert := "ACGTTGCAACGGTCAGTCAATGCA"
+	rc := []byte(insert)
+	fastq.ReverseComplement(rc)
+	// Unknown adapters after a short insert
+	e1, e2 := entry(insert+"GGGCCCTTTAAA"), entry(string(rc)+"CCCAAATTTGGG")
+	tr := NewAdapterTrimmer(nil, nil)
+	tr.Trim(e1, e2)
+	if string(e1.Sequence) != insert || string(e2.Sequence) != string(rc) {
+		t.Errorf("got %s/%s, want %s/%s", e1.Sequence, e2.Sequence, insert, rc)
+	}
+	if _, _, overlap := tr.Hits(); overlap != 1 {
+		t.Errorf("got %d insert overlap hits, want 1", overlap)
+	}
+
+	// Insert longer than the reads
+	e1, e2 = entry(insert), entry("TTTTTTTTTTTTTTTTTTTTTTTT")
+	tr.Trim(e1, e2)
+	if string(e1.Sequence) != insert || len(e2.Sequence) != len(insert) {
+		t.Errorf("reads without overlap have been trimmed: %s/%s", e1.Sequence, e2.Sequence)
+	}
+}
+
+func TestReadAdapters(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "adapters.fa")
+	if err := os.WriteFile(file, []byte(">polyA\naaaaaaaaaa\n>Nextera\nCTGTCTCTTATACACATCT\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	adapters, err := ReadAdapters(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(adapters) != 2 || adapters[0].Name != "polyA" || string(adapters[0].Sequence) != "AAAAAAAAAA" || adapters[1].Name != "Nextera" {
+		t.Errorf("unexpected adapters: %v", adapters)
+	}
+}
@@ -0,0 +1,149 @@
+// Package trim removes unwanted parts of reads: low quality ends
+// and adapter sequences.
+package trim
+
+import (
+	"fmt"
+
+	"github.com/fredericlemoine/fastqutils/fastq"
+)
+
+// Quality trimming methods
+const (
+	SLIDING_WINDOW = iota // fastp --cut_tail (window moved from the 3' end)
+	RUNNING_SUM           // BWA -q / cutadapt -q
+)
+
+// Read ends to trim
+const (
+	END_3 = 1 << iota
+	END_5
+	BOTH_ENDS = END_3 | END_5
+)
+
+// MethodFromString returns the quality trimming method corresponding
+// to the given name (sliding or bwa).
+func MethodFromString(method string) (m int, err error) {
+	switch method {
+	case "sliding":
+		m = SLIDING_WINDOW
+	case "bwa":
+		m = RUNNING_SUM
+	default:
+		err = fmt.Errorf("this trimming method does not exist : %s, possible values are : sliding, bwa", method)
+	}
+	return
+}
+
+// EndsFromString returns the read ends corresponding to the given
+// name (3, 5 or both).
+func EndsFromString(ends string) (e int, err error) {
+	switch ends {
+	case "3":
+		e = END_3
+	case "5":
+		e = END_5
+	case "both":
+		e = BOTH_ENDS
+	default:
+		err = fmt.Errorf("this read end does not exist : %s, possible values are : 3, 5, both", ends)
+	}
+	return
+}
+
+// QualityTrimmer trims low quality bases from the ends of reads.
+type QualityTrimmer struct {
+	Method int // SLIDING_WINDOW or RUNNING_SUM
+	Ends   int // END_3, END_5 or BOTH_ENDS
+	Offset int // Offset of the quality encoding (see stats.Encoding.Offset)
+	Cutoff int // Quality cutoff
+	Window int // Window size, for SLIDING_WINDOW
+}
+
+// Bounds returns the part qual[start:end] of the quality string to
+// keep. The 3' end is trimmed first.
+func (t *QualityTrimmer) Bounds(qual []byte) (start, end int) {
+	end = len(qual)
+	q3 := func(i int) int { return int(qual[i]) - t.Offset }
+	if t.Ends&END_3 != 0 {
+		end = t.keep(q3, end)
+	}
+	if t.Ends&END_5 != 0 {
+		// The 5' end is trimmed as the 3' end of the reversed read
+		q5 := func(i int) int { return int(qual[end-1-i]) - t.Offset }
+		start = end - t.keep(q5, end)
+	}
+	return
+}
+
+// keep returns the number of bases to keep at the beginning of a read
+// of length n whose i-th quality is q(i), trimming its end.
+func (t *QualityTrimmer) keep(q func(i int) int, n int) int {
+	if t.Method == RUNNING_SUM {
+		return runningSum(q, n, t.Cutoff)
+	}
+	return slidingWindow(q, n, t.Window, t.Cutoff)
+}
+
+// Trim removes the low quality ends of the sequence and the quality
+// of entry. The fields of entry are resliced, not copied. Reads
+// without qualities (e.g. read from fasta files) are not trimmed.
+// Only the qualities of the bases of the sequence are considered, if
+// the quality is longer than the sequence (non strict parsing).
+func (t *QualityTrimmer) Trim(entry *fastq.FastqEntry) {
+	if len(entry.Quality) == 0 {
+		return
+	}
+	n := min(len(entry.Sequence), len(entry.Quality))
+	start, end := t.Bounds(entry.Quality[:n])
+	entry.Sequence = entry.Sequence[start:end]
+	entry.Quality = entry.Quality[start:end]
+}
+
+// slidingWindow moves a window from the end of the read towards its
+// beginning, and removes the bases of the window as long as its mean
+// quality is below cutoff. The bases below cutoff at the end of the
+// first good window are then removed too.
+func slidingWindow(q func(i int) int, n, window, cutoff int) int {
+	if window < 1 {
+		window = 1
+	}
+	if window > n {
+		window = n
+	}
+	sum := 0
+	for i := n - window; i < n; i++ {
+		sum += q(i)
+	}
+	keep := 0
+	for i := n - window; i >= 0; i-- {
+		if i < n-window {
+			sum += q(i) - q(i+window)
+		}
+		if sum >= cutoff*window {
+			keep = i + window
+			break
+		}
+	}
+	for keep > 0 && q(keep-1) < cutoff {
+		keep--
+	}
+	return keep
+}
+
+// runningSum is the BWA trimming algorithm: it computes, from the end
+// of the read, the partial sums of cutoff-q(i), and cuts the read where
+// this sum is maximal.
+func runningSum(q func(i int) int, n, cutoff int) int {
+	sum, max, keep := 0, 0, n
+	for i := n - 1; i >= 0; i-- {
+		sum += cutoff - q(i)
+		if sum < 0 {
+			break
+		}
+		if sum > max {
+			max, keep = sum, i
+		}
+	}
+	return keep
+}
@@ -0,0 +1,54 @@
+package trim
+
+import (
+	"testing"
+
+	"github.com/fredericlemoine/fastqutils/fastq"
+)
+
+func TestQualityTrimmer(t *testing.T) {
+	for _, c := range []struct {
+		method, ends, window int
+		qual                 string
+		start, end           int
+	}{
+		// Qualities are Phred+33, cutoff is 20 ('5')
+		{SLIDING_WINDOW, END_3, 1, "IIIII####", 0, 5},
+		{SLIDING_WINDOW, END_3, 4, "IIIII####", 0, 5},
+		{SLIDING_WINDOW, END_3, 4, "IIIIIIIII", 0, 9},
+		{SLIDING_WINDOW, END_3, 4, "#########", 0, 0},
+		{SLIDING_WINDOW, END_3, 4, "II#IIIIII", 0, 9},
+		{SLIDING_WINDOW, END_3, 4, "IIIII#I##", 0, 7},
+		{SLIDING_WINDOW, END_3, 20, "IIIII####", 0, 5},
+		{SLIDING_WINDOW, END_5, 1, "##IIIII##", 2, 9},
+		{SLIDING_WINDOW, BOTH_ENDS, 1, "##IIIII##", 2, 7},
+		{SLIDING_WINDOW, BOTH_ENDS, 4, "", 0, 0},
+		{RUNNING_SUM, END_3, 0, "IIIII####", 0, 5},
+		{RUNNING_SUM, END_3, 0, "IIIII#I##", 0, 7},
+		// A base at the cutoff in a low quality end is trimmed with it
+		{RUNNING_SUM, END_3, 0, "IIIII#5##", 0, 5},
+		{RUNNING_SUM, END_3, 0, "IIIII+S##", 0, 7},
+		{RUNNING_SUM, BOTH_ENDS, 0, "##IIIII##", 2, 7},
+		{RUNNING_SUM, BOTH_ENDS, 0, "#########", 0, 0},
+	} {
+		tr := &QualityTrimmer{Method: c.method, Ends: c.ends, Offset: 33, Cutoff: 20, Window: c.window}
+		if start, end := tr.Bounds([]byte(c.qual)); start != c.start || end != c.end {
+			t.Errorf("method %d, ends %d, window %d, %q: got [%d:%d], want [%d:%d]", c.method, c.ends, c.window, c.qual, start, end, c.start, c.end)
+		}
+	}
+
+	// Reads without qualities are kept
+	tr := &QualityTrimmer{Method: SLIDING_WINDOW, Ends: BOTH_ENDS, Offset: 33, Cutoff: 20, Window: 4}
+	entry := &fastq.FastqEntry{Name: []byte(">read"), Sequence: []byte("ACGT")}
+	if tr.Trim(entry); string(entry.Sequence) != "ACGT" {
+		t.Errorf("read without qualities: got %s, want ACGT", entry.Sequence)
+	}
+
+	// A quality longer than the sequence does not extend it into the
+	// rest of its buffer
+	buf := []byte("ACGT+\n  ")
+	entry = &fastq.FastqEntry{Name: []byte("@read"), Sequence: buf[:4], Quality: []byte("IIIIIIII")}
+	if tr.Trim(entry); string(entry.Sequence) != "ACGT" || string(entry.Quality) != "IIII" {
+		t.Errorf("quality longer than the sequence: got %q and %q, want ACGT and IIII", entry.Sequence, entry.Quality)
+	}
+}